@@ -4,14 +4,19 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"runtime"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/urfave/cli/v2"
 
+	"LinkMaskirator/masking"
 	"LinkMaskirator/service"
 )
 
@@ -63,7 +68,7 @@ func main() {
 				Name:    "mask",
 				Aliases: []string{"m"},
 				Usage:   "Маскировка ссылок в тексте",
-				Flags: []cli.Flag{
+				Flags: append([]cli.Flag{
 					&cli.StringFlag{
 						Name:     "source",
 						Aliases:  []string{"s"},
@@ -95,10 +100,216 @@ func main() {
 						Value:   5,
 						Usage:   "Таймаут выполнения программы. Чаще используется в паре с --slowmode",
 					},
-				},
+					&cli.StringFlag{
+						Name:    "format",
+						Aliases: []string{"f"},
+						Value:   "auto",
+						Usage:   "Формат исходного файла (auto|text|markdown|html|json|jsonl|csv|tsv|yaml|toml|archive|office|email). auto - по расширению",
+					},
+					&cli.StringFlag{
+						Name:  "md-code",
+						Value: service.CodePolicyMask,
+						Usage: "Markdown: что делать с кодом в `...` и ``` блоках (mask|keep)",
+					},
+					&cli.BoolFlag{
+						Name:  "md-link-text",
+						Value: false,
+						Usage: "Markdown: маскировать текст ссылки, если он сам является URL",
+					},
+					&cli.BoolFlag{
+						Name:  "html-neutralize",
+						Value: false,
+						Usage: "HTML: заменять замаскированные ссылки в href/src/action на #masked",
+					},
+					&cli.StringSliceFlag{
+						Name:  "json-include",
+						Usage: "JSON: маскировать только выбранные поля ($.a.b, items[*].url, $..url)",
+					},
+					&cli.StringSliceFlag{
+						Name:  "json-exclude",
+						Usage: "JSON: не маскировать выбранные поля",
+					},
+					&cli.StringFlag{
+						Name:  "json-malformed",
+						Value: service.MalformedText,
+						Usage: "JSON: что делать с некорректными записями (text|keep|skip|fail)",
+					},
+					&cli.StringFlag{
+						Name:  "csv-delimiter",
+						Usage: "CSV: разделитель полей (по умолчанию запятая для csv и табуляция для tsv, tab - табуляция)",
+					},
+					&cli.BoolFlag{
+						Name:  "csv-header",
+						Value: false,
+						Usage: "CSV: первая строка - заголовок, она не маскируется",
+					},
+					&cli.StringSliceFlag{
+						Name:  "csv-columns",
+						Usage: "CSV: колонки для маскировки - имя из заголовка или номер с 1 (по умолчанию все)",
+					},
+					&cli.StringSliceFlag{
+						Name:  "key-path",
+						Usage: "YAML/TOML: пути ключей для маскировки, например services.*.url (по умолчанию все значения)",
+					},
+					&cli.StringFlag{
+						Name:  "archive-binary",
+						Value: service.BinaryCopy,
+						Usage: "Архивы (zip, tar, tar.gz): что делать с бинарными файлами внутри (copy|drop)",
+					},
+					&cli.StringFlag{
+						Name:  "report",
+						Usage: "Путь к JSON-отчету о маскировке (для архивов - результат по каждому файлу)",
+					},
+					&cli.BoolFlag{
+						Name:  "follow",
+						Value: false,
+						Usage: "Следить за растущим текстовым файлом как tail -F и дописывать новые строки в конечный файл (--timeout не действует)",
+					},
+					&cli.StringFlag{
+						Name:  "checkpoint",
+						Usage: "Файл с позицией для --follow и --resume, по умолчанию <dest>.checkpoint для --follow и <dest>.resume для --resume",
+					},
+					&cli.BoolFlag{
+						Name:  "resume",
+						Value: false,
+						Usage: "Продолжить прерванную обработку текстового файла с сохраненной позиции (позиция сохраняется только с --checkpoint или --resume)",
+					},
+					&cli.DurationFlag{
+						Name:  "follow-interval",
+						Value: time.Second,
+						Usage: "Как часто --follow проверяет источник на новые строки",
+					},
+					&cli.StringFlag{
+						Name:  "placeholder-map",
+						Usage: "JSON-файл с соответствием заглушек [LINK-n] стратегии numbered и исходных значений (только с numbered, без --follow)",
+					},
+					&cli.IntFlag{
+						Name:  "gzip-level",
+						Value: 0,
+						Usage: "Сжать результат gzip с уровнем 1-9 (0 - только если конечный файл *.gz). Сжатый вход (gz, zlib, bz2) распаковывается автоматически",
+					},
+				}, ruleFlags()...),
 
 				Action: maskAction,
 			},
+			{
+				Name:  "serve",
+				Usage: "HTTP-сервис маскировки: POST /mask, POST /check, GET /healthz",
+				Flags: append([]cli.Flag{
+					&cli.StringFlag{
+						Name:  "addr",
+						Value: ":8080",
+						Usage: "Адрес, на котором слушает сервер",
+					},
+					&cli.IntFlag{
+						Name:    "workers",
+						Aliases: []string{"wc"},
+						Value:   10,
+						Usage:   "Количество горутин для обработки одного запроса",
+					},
+					&cli.Int64Flag{
+						Name:  "max-body-size",
+						Value: 10 << 20,
+						Usage: "Максимальный размер тела запроса в байтах",
+					},
+					&cli.IntFlag{
+						Name:  "request-timeout",
+						Value: 30,
+						Usage: "Таймаут обработки одного запроса в секундах",
+					},
+					&cli.IntFlag{
+						Name:  "batch-lines",
+						Value: 1000,
+						Usage: "Сколько строк text/plain маскируется за раз при потоковой обработке",
+					},
+					&cli.IntFlag{
+						Name:  "shutdown-timeout",
+						Value: 5,
+						Usage: "Сколько секунд ждать завершения активных запросов при остановке",
+					},
+				}, ruleFlags()...),
+
+				Action: serveAction,
+			},
+			{
+				Name:  "proxy",
+				Usage: "Обратный прокси, маскирующий ссылки в ответах upstream (text/plain, HTML, JSON)",
+				Flags: append([]cli.Flag{
+					&cli.StringFlag{
+						Name:     "upstream",
+						Aliases:  []string{"u"},
+						Usage:    "Адрес проксируемого приложения, например http://localhost:8080",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:  "addr",
+						Value: ":8000",
+						Usage: "Адрес, на котором слушает прокси",
+					},
+					&cli.IntFlag{
+						Name:    "workers",
+						Aliases: []string{"wc"},
+						Value:   10,
+						Usage:   "Количество горутин для маскировки одного ответа",
+					},
+					&cli.Int64Flag{
+						Name:  "max-body-size",
+						Value: 32 << 20,
+						Usage: "Максимальный размер HTML/JSON ответа в байтах (text/plain маскируется потоково без ограничения)",
+					},
+					&cli.BoolFlag{
+						Name:  "html-neutralize",
+						Value: false,
+						Usage: "HTML: заменять замаскированные ссылки в href/src/action на #masked",
+					},
+					&cli.BoolFlag{
+						Name:  "access-log",
+						Value: false,
+						Usage: "Писать access-лог; ссылки в пути и Referer, значения параметров запроса маскируются",
+					},
+					&cli.IntFlag{
+						Name:  "shutdown-timeout",
+						Value: 5,
+						Usage: "Сколько секунд ждать завершения активных запросов при остановке",
+					},
+				}, ruleFlags()...),
+
+				Action: proxyAction,
+			},
+			{
+				Name:  "git-hook",
+				Usage: "Проверка добавленных в индекс строк перед коммитом (git diff --cached)",
+				Flags: append([]cli.Flag{
+					&cli.BoolFlag{
+						Name:  "fix",
+						Value: false,
+						Usage: "Не останавливать коммит, а замаскировать найденное в индексе и добавить файлы заново",
+					},
+				}, ruleFlags()...),
+
+				Action: gitHookAction,
+
+				Subcommands: []*cli.Command{
+					{
+						Name:  "install",
+						Usage: "Установить pre-commit хук в текущий репозиторий",
+						Flags: append([]cli.Flag{
+							&cli.BoolFlag{
+								Name:  "fix",
+								Value: false,
+								Usage: "Хук маскирует найденное вместо остановки коммита",
+							},
+							&cli.BoolFlag{
+								Name:  "force",
+								Value: false,
+								Usage: "Перезаписать существующий pre-commit хук",
+							},
+						}, ruleFlags()...),
+
+						Action: gitHookInstallAction,
+					},
+				},
+			},
 		},
 
 		Metadata: map[string]interface{}{
@@ -193,21 +404,115 @@ func setupLogger(levelStr string, jsonOutput bool) {
 
 }
 
-func runMaskingProcess(ctx context.Context, inputFile, outputFile string, workers int, slowmode bool) error {
-	if workers < 0 {
-		return fmt.Errorf("количество воркеров должно быть положительным: %d", workers)
+// maskConfig - параметры команды mask после разбора флагов
+type maskConfig struct {
+	inputFile     string
+	outputFile    string
+	workers       int
+	slowmode      bool
+	masker        *masking.Masker
+	format        service.Format
+	formatOptions service.FormatOptions
+	reportFile    string
+	// resume - продолжить прерванный запуск; checkpoint - файл с позицией,
+	// rules - описание правил, с которыми позиция сохранена
+	resume     bool
+	checkpoint string
+	rules      string
+	// placeholderMap - файл для соответствия заглушек --strategy numbered и значений
+	placeholderMap string
+}
+
+// resumable - сохранять ли позицию, чтобы прерванный запуск можно было продолжить
+func (cfg maskConfig) resumable() bool {
+	return cfg.resume || cfg.checkpoint != ""
+}
+
+func runMaskingProcess(ctx context.Context, cfg maskConfig) error {
+	if cfg.workers < 0 {
+		return fmt.Errorf("количество воркеров должно быть положительным: %d", cfg.workers)
 	}
 
-	if inputFile == "" {
+	if cfg.inputFile == "" {
 		return fmt.Errorf("не указан исходный файл")
 	}
 
-	slog.DebugContext(ctx, "создание сервиса", "workers", workers, "max goroutines", runtime.NumCPU())
-	factory := service.NewServiceFactory(workers, slowmode)
+	slog.DebugContext(ctx, "создание сервиса", "workers", cfg.workers, "max goroutines", runtime.NumCPU())
+	factory := service.NewServiceFactory(cfg.workers, cfg.slowmode)
+	factory.SetMasker(cfg.masker)
+	if err := factory.SetFormat(cfg.format, cfg.formatOptions); err != nil {
+		return err
+	}
+
+	// С --checkpoint или --resume обычный текст обрабатывается пачками
+	// с сохранением позиции, чтобы прерванный запуск можно было продолжить.
+	// Нумерация заглушек между запусками не сохраняется, поэтому с ними
+	// продолжение недоступно.
+	if cfg.resumable() {
+		resumeErr := service.SupportsResume(cfg.format, cfg.inputFile, cfg.outputFile, cfg.formatOptions.GzipLevel)
+		if resumeErr == nil && cfg.masker.Ordered() {
+			resumeErr = fmt.Errorf("продолжение не поддерживается со стратегией numbered")
+		}
+		if resumeErr != nil {
+			return resumeErr
+		}
+
+		report, err := service.RunResumable(ctx, service.ResumableConfig{
+			Source:     cfg.inputFile,
+			Dest:       cfg.outputFile,
+			Checkpoint: cfg.checkpoint,
+			Resume:     cfg.resume,
+			Rules:      cfg.rules,
+			Workers:    cfg.workers,
+			SlowMode:   cfg.slowmode,
+			Masker:     cfg.masker,
+		})
+		if err != nil {
+			return err
+		}
+		return saveReport(ctx, cfg, report)
+	}
+
+	svc := factory.CreateMaskService(cfg.inputFile, cfg.outputFile)
 
-	svc := factory.CreateMaskService(inputFile, outputFile)
+	// При прерывании начало результата уже записано с заглушками, поэтому
+	// выданные заглушки сохраняются и в этом случае
+	runErr := svc.Run(ctx)
+	if runErr == nil || len(cfg.masker.Placeholders()) > 0 {
+		if err := savePlaceholderMap(ctx, cfg); err != nil {
+			return err
+		}
+	}
+	if runErr != nil {
+		return runErr
+	}
+	return saveReport(ctx, cfg, svc.Report())
+}
 
-	return svc.Run(ctx)
+// savePlaceholderMap сохраняет соответствие нумерованных заглушек и скрытых
+// ими значений. Файл содержит исходные данные, поэтому доступен только владельцу.
+func savePlaceholderMap(ctx context.Context, cfg maskConfig) error {
+	if cfg.placeholderMap == "" {
+		return nil
+	}
+	if err := service.WritePlaceholderMap(cfg.placeholderMap, cfg.masker.Placeholders()); err != nil {
+		return fmt.Errorf("ошибка сохранения соответствия заглушек: %w", err)
+	}
+	slog.DebugContext(ctx, "соответствие заглушек сохранено", "map", cfg.placeholderMap)
+	return nil
+}
+
+func saveReport(ctx context.Context, cfg maskConfig, report *service.Report) error {
+	if cfg.reportFile == "" {
+		return nil
+	}
+	report.Input = cfg.inputFile
+	report.Output = cfg.outputFile
+	if err := service.WriteReport(cfg.reportFile, report); err != nil {
+		return fmt.Errorf("ошибка сохранения отчета: %w", err)
+	}
+	slog.DebugContext(ctx, "отчет сохранен", "report", cfg.reportFile)
+	return nil
 }
 
 func maskAction(c *cli.Context) error {
@@ -221,11 +526,46 @@ func maskAction(c *cli.Context) error {
 		return fmt.Errorf("Ошибка длительности таймаута. Таймаут не может быть меньше 1 секунды")
 	}
 
-	appCtx, ok := c.App.Metadata["app_ctx"].(context.Context)
-	if !ok {
-		appCtx = context.Background()
+	masker, err := parseMasker(c)
+	if err != nil {
+		return err
+	}
+
+	format, err := service.ParseFormat(c.String("format"))
+	if err != nil {
+		return cli.Exit(err.Error(), 1)
+	}
+	formatOptions := service.DefaultFormatOptions()
+	formatOptions.MarkdownCode = c.String("md-code")
+	formatOptions.MarkdownLinkText = c.Bool("md-link-text")
+	formatOptions.HTMLNeutralize = c.Bool("html-neutralize")
+	formatOptions.JSONInclude = c.StringSlice("json-include")
+	formatOptions.JSONExclude = c.StringSlice("json-exclude")
+	formatOptions.JSONMalformed = c.String("json-malformed")
+	formatOptions.CSVDelimiter = c.String("csv-delimiter")
+	formatOptions.CSVHeader = c.Bool("csv-header")
+	formatOptions.CSVColumns = c.StringSlice("csv-columns")
+	formatOptions.KeyPaths = c.StringSlice("key-path")
+	formatOptions.ArchiveBinary = c.String("archive-binary")
+	formatOptions.GzipLevel = c.Int("gzip-level")
+
+	if c.String("placeholder-map") != "" {
+		if !masker.Ordered() {
+			return cli.Exit("--placeholder-map задается только вместе со стратегией numbered", 1)
+		}
+		if c.Bool("follow") {
+			return cli.Exit("--placeholder-map не поддерживается с --follow", 1)
+		}
 	}
-	ctx, cancel := context.WithTimeout(appCtx, time.Duration(timeOut)*time.Second)
+
+	if c.Bool("follow") {
+		if err := requireStateless(masker, "mask --follow"); err != nil {
+			return err
+		}
+		return followAction(c, masker, format)
+	}
+
+	ctx, cancel := context.WithTimeout(appContext(c), time.Duration(timeOut)*time.Second)
 	defer cancel()
 
 	slog.InfoContext(ctx, "начало маскировки",
@@ -233,15 +573,25 @@ func maskAction(c *cli.Context) error {
 		"output", outputFile,
 		"count workers", countWorkers,
 		"slow mode status", isSlowMode,
-		"timeout", timeOut)
-
-	err := runMaskingProcess(
-		ctx,
-		inputFile,
-		outputFile,
-		countWorkers,
-		isSlowMode,
-	)
+		"timeout", timeOut,
+		"rules", masker.RuleNames(),
+		"format", format)
+
+	err = runMaskingProcess(ctx, maskConfig{
+		inputFile:      inputFile,
+		outputFile:     outputFile,
+		workers:        countWorkers,
+		slowmode:       isSlowMode,
+		masker:         masker,
+		format:         format,
+		formatOptions:  formatOptions,
+		reportFile:     c.String("report"),
+		resume:         c.Bool("resume"),
+		checkpoint:     c.String("checkpoint"),
+		placeholderMap: c.String("placeholder-map"),
+		rules: fmt.Sprintf("%s|%s|%s|%s", c.String("rules"), c.String("strategy"),
+			strings.Join(c.StringSlice("phone-countries"), ","), masker.DomainFilter("link")),
+	})
 	timeDeadline, _ := ctx.Deadline()
 	if err != nil {
 		slog.ErrorContext(ctx, "ошибка при маскировке",
@@ -249,7 +599,10 @@ func maskAction(c *cli.Context) error {
 
 		if ctx.Err() == context.DeadlineExceeded {
 			slog.InfoContext(ctx, "Время таймаута истекло", "timeout (s)", timeOut, "ctx.Deadline()", timeDeadline)
-			return cli.Exit("Превышено время ожидания", 2)
+			if c.Bool("resume") || c.String("checkpoint") != "" {
+				return cli.Exit("Превышено время ожидания. Продолжить обработку текстового файла можно с --resume", 2)
+			}
+			return cli.Exit("Превышено время ожидания. Чтобы прерванную обработку текстового файла можно было продолжить, запускайте ее с --checkpoint", 2)
 		}
 		return cli.Exit(fmt.Sprintf("Ошибка маскировки: %v", err), 1)
 	}
@@ -258,3 +611,313 @@ func maskAction(c *cli.Context) error {
 	return nil
 
 }
+
+// ruleFlags - флаги правил маскировки, общие для команд
+func ruleFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:    "rules",
+			Aliases: []string{"r"},
+			Value:   "link",
+			Usage:   "Правила маскировки через запятую (link|phone|card), стратегию можно задать для правила: phone:last4,link:redact",
+		},
+		&cli.StringFlag{
+			Name:  "strategy",
+			Value: "stars",
+			Usage: "Стратегия замены по умолчанию (stars|last4|domain|domain-unicode|domain-ascii|redact|label|pad|numbered). domain оставляет открытым домен ссылки: как в тексте, в Unicode или в punycode; redact, label и pad скрывают длину: https://[REDACTED], [URL#1], случайное число '*'; numbered - сквозная нумерация одинаковых значений [LINK-1], [LINK-2]",
+		},
+		&cli.StringSliceFlag{
+			Name:  "phone-countries",
+			Value: cli.NewStringSlice("ru"),
+			Usage: "Страны для шаблонов телефонов (ru|kz|by|ua|intl)",
+		},
+		&cli.StringSliceFlag{
+			Name:  "allow-domains",
+			Usage: "Домены, ссылки на которые не маскируются: example.com, *.example.com (поддомены), .example.com (домен и поддомены)",
+		},
+		&cli.StringFlag{
+			Name:  "allow-file",
+			Usage: "Файл с разрешенными доменами, по одному шаблону на строку (# - комментарий)",
+		},
+		&cli.StringSliceFlag{
+			Name:  "deny-domains",
+			Usage: "Домены, которые маскируются, даже если подходят под --allow-domains",
+		},
+		&cli.StringFlag{
+			Name:  "deny-file",
+			Usage: "Файл с запрещенными доменами, по одному шаблону на строку",
+		},
+	}
+}
+
+func parseMasker(c *cli.Context) (*masking.Masker, error) {
+	masker, err := masking.ParseRules(c.String("rules"), c.String("strategy"), c.StringSlice("phone-countries"))
+	if err != nil {
+		return nil, cli.Exit(fmt.Sprintf("Ошибка в правилах маскировки: %v", err), 1)
+	}
+
+	filter, err := domainFilter(c)
+	if err != nil {
+		return nil, cli.Exit(fmt.Sprintf("Ошибка в списках доменов: %v", err), 1)
+	}
+	if !filter.Empty() {
+		if err := masker.SetDomainFilter("link", filter); err != nil {
+			return nil, cli.Exit(fmt.Sprintf("Списки доменов работают только с правилом link: %v", err), 1)
+		}
+	}
+	return masker, nil
+}
+
+// requireStateless запрещает стратегию numbered в режимах, которые живут долго
+// или обслуживают разных клиентов: общая нумерация копила бы значения в памяти
+// и связывала данные разных запросов одними и теми же заглушками
+func requireStateless(masker *masking.Masker, mode string) error {
+	if masker.Ordered() {
+		return cli.Exit(fmt.Sprintf("Стратегия numbered не поддерживается в %s: нумерация работает только в mask без --follow", mode), 1)
+	}
+	return nil
+}
+
+// domainFilter собирает списки доменов из флагов и файлов
+func domainFilter(c *cli.Context) (*masking.DomainFilter, error) {
+	allow := c.StringSlice("allow-domains")
+	deny := c.StringSlice("deny-domains")
+	if name := c.String("allow-file"); name != "" {
+		patterns, err := masking.LoadDomainList(name)
+		if err != nil {
+			return nil, err
+		}
+		allow = append(allow, patterns...)
+	}
+	if name := c.String("deny-file"); name != "" {
+		patterns, err := masking.LoadDomainList(name)
+		if err != nil {
+			return nil, err
+		}
+		deny = append(deny, patterns...)
+	}
+	return masking.NewDomainFilter(allow, deny)
+}
+
+func appContext(c *cli.Context) context.Context {
+	appCtx, ok := c.App.Metadata["app_ctx"].(context.Context)
+	if !ok {
+		return context.Background()
+	}
+	return appCtx
+}
+
+func serveAction(c *cli.Context) error {
+	masker, err := parseMasker(c)
+	if err != nil {
+		return err
+	}
+	if err := requireStateless(masker, "serve"); err != nil {
+		return err
+	}
+
+	cfg := service.DefaultServerConfig()
+	cfg.Workers = c.Int("workers")
+	cfg.MaxBodySize = c.Int64("max-body-size")
+	cfg.Timeout = time.Duration(c.Int("request-timeout")) * time.Second
+	cfg.BatchLines = c.Int("batch-lines")
+	if cfg.Workers < 1 || cfg.MaxBodySize < 1 || cfg.Timeout <= 0 || cfg.BatchLines < 1 {
+		return cli.Exit("Количество воркеров, размер тела, таймаут и размер пачки должны быть положительными", 1)
+	}
+
+	server := &http.Server{
+		Addr:              c.String("addr"),
+		Handler:           service.NewServer(masker, cfg).Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	shutdownTimeout := time.Duration(c.Int("shutdown-timeout")) * time.Second
+
+	slog.Info("запуск HTTP-сервера",
+		"addr", server.Addr,
+		"rules", masker.RuleNames(),
+		"workers", cfg.Workers,
+		"max body size", cfg.MaxBodySize,
+		"request timeout", cfg.Timeout)
+
+	return runServer(appContext(c), server, shutdownTimeout)
+}
+
+// runServer работает до отмены контекста приложения (сигнал graceful shutdown),
+// затем дожидается активных запросов не дольше shutdownTimeout
+func runServer(ctx context.Context, server *http.Server, shutdownTimeout time.Duration) error {
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		return cli.Exit(fmt.Sprintf("Ошибка HTTP-сервера: %v", err), 1)
+	case <-ctx.Done():
+	}
+
+	slog.Info("остановка HTTP-сервера", "shutdown timeout", shutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("ошибка остановки HTTP-сервера: %w", err)
+	}
+	slog.Info("HTTP-сервер остановлен")
+	return nil
+}
+
+func proxyAction(c *cli.Context) error {
+	masker, err := parseMasker(c)
+	if err != nil {
+		return err
+	}
+	if err := requireStateless(masker, "proxy"); err != nil {
+		return err
+	}
+
+	upstream, err := url.Parse(c.String("upstream"))
+	if err != nil || upstream.Scheme == "" || upstream.Host == "" {
+		return cli.Exit(fmt.Sprintf("Некорректный адрес upstream: %q", c.String("upstream")), 1)
+	}
+
+	cfg := service.DefaultProxyConfig()
+	cfg.Workers = c.Int("workers")
+	cfg.MaxBodySize = c.Int64("max-body-size")
+	cfg.Options.HTMLNeutralize = c.Bool("html-neutralize")
+	if cfg.Workers < 1 || cfg.MaxBodySize < 1 {
+		return cli.Exit("Количество воркеров и размер ответа должны быть положительными", 1)
+	}
+
+	handler := service.NewMaskingProxy(upstream, masker, cfg)
+	if c.Bool("access-log") {
+		handler = service.NewAccessLogMiddleware(slog.Default(), masker)(handler)
+	}
+
+	server := &http.Server{
+		Addr:              c.String("addr"),
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	slog.Info("запуск маскирующего прокси",
+		"addr", server.Addr,
+		"upstream", upstream.String(),
+		"rules", masker.RuleNames())
+
+	return runServer(appContext(c), server, time.Duration(c.Int("shutdown-timeout"))*time.Second)
+}
+
+func gitHookAction(c *cli.Context) error {
+	masker, err := parseMasker(c)
+	if err != nil {
+		return err
+	}
+	if err := requireStateless(masker, "git-hook"); err != nil {
+		return err
+	}
+	ctx := appContext(c)
+
+	findings, err := service.ScanStaged(ctx, ".", masker)
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Ошибка чтения индекса: %v", err), 1)
+	}
+	if len(findings) == 0 {
+		return nil
+	}
+
+	if c.Bool("fix") {
+		if err := service.FixStaged(ctx, ".", masker, findings); err != nil {
+			return cli.Exit(fmt.Sprintf("Ошибка маскировки индекса: %v", err), 1)
+		}
+		for _, f := range findings {
+			fmt.Fprintf(os.Stderr, "замаскировано %s\n", f)
+		}
+		return nil
+	}
+
+	for _, f := range findings {
+		fmt.Fprintln(os.Stderr, f)
+	}
+	return cli.Exit(fmt.Sprintf("Коммит остановлен: найдено %d совпадений в добавленных строках. "+
+		"Замаскируйте их или запустите git-hook --fix", len(findings)), 1)
+}
+
+func gitHookInstallAction(c *cli.Context) error {
+	// Правила проверяются сразу, а не при первом коммите
+	masker, err := parseMasker(c)
+	if err != nil {
+		return err
+	}
+	if err := requireStateless(masker, "git-hook"); err != nil {
+		return err
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Не удалось определить путь к программе: %v", err), 1)
+	}
+	command := []string{exe, "git-hook",
+		"--rules", c.String("rules"),
+		"--strategy", c.String("strategy"),
+	}
+	for _, country := range c.StringSlice("phone-countries") {
+		command = append(command, "--phone-countries", country)
+	}
+	for _, name := range []string{"allow-domains", "deny-domains"} {
+		for _, pattern := range c.StringSlice(name) {
+			command = append(command, "--"+name, pattern)
+		}
+	}
+	// Хук запускается из корня репозитория, поэтому пути к спискам делаем абсолютными
+	for _, name := range []string{"allow-file", "deny-file"} {
+		if file := c.String(name); file != "" {
+			abs, err := filepath.Abs(file)
+			if err != nil {
+				return cli.Exit(fmt.Sprintf("Не удалось определить путь к %s: %v", file, err), 1)
+			}
+			command = append(command, "--"+name, abs)
+		}
+	}
+	if c.Bool("fix") {
+		command = append(command, "--fix")
+	}
+
+	path, err := service.InstallHook(appContext(c), ".", command, c.Bool("force"))
+	if err != nil {
+		return cli.Exit(fmt.Sprintf("Ошибка установки хука: %v", err), 1)
+	}
+	slog.Info("pre-commit хук установлен", "path", path)
+	return nil
+}
+
+func followAction(c *cli.Context, masker *masking.Masker, format service.Format) error {
+	if format == service.FormatAuto {
+		format = service.DetectFormat(c.String("source"))
+	}
+	if format != service.FormatText {
+		return cli.Exit(fmt.Sprintf("--follow поддерживает только текстовые файлы, а не %s (явно: --format text)", format), 1)
+	}
+	cfg := service.FollowConfig{
+		Source:       c.String("source"),
+		Dest:         c.String("dest"),
+		Checkpoint:   c.String("checkpoint"),
+		PollInterval: c.Duration("follow-interval"),
+		Masker:       masker,
+	}
+	if cfg.PollInterval <= 0 {
+		return cli.Exit("Интервал --follow-interval должен быть положительным", 1)
+	}
+
+	ctx := appContext(c)
+	slog.InfoContext(ctx, "слежение за файлом",
+		"input", cfg.Source,
+		"output", cfg.Dest,
+		"interval", cfg.PollInterval,
+		"rules", masker.RuleNames())
+
+	if err := service.Follow(ctx, cfg); err != nil {
+		return cli.Exit(fmt.Sprintf("Ошибка слежения за файлом: %v", err), 1)
+	}
+	slog.InfoContext(ctx, "слежение остановлено", "output", cfg.Dest)
+	return nil
+}
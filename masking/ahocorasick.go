@@ -0,0 +1,129 @@
+package masking
+
+// prefixMatcher - автомат Ахо-Корасик для поиска набора префиксов (схем,
+// ключевых слов) без учета регистра ASCII. Строится один раз на набор
+// правил и дальше только читается, поэтому один экземпляр безопасно
+// использовать из всех воркеров. Время поиска линейно по длине строки и не
+// зависит от числа шаблонов.
+//
+// Переходы хранятся полной таблицей (goto уже объединен с failure-ссылками)
+// по классам байт: байты, которых нет ни в одном шаблоне, попадают в общий
+// класс 0, поэтому таблица занимает states*classes, а не states*256.
+type prefixMatcher struct {
+	patterns []string
+	// class - класс байта после приведения к нижнему регистру ASCII
+	class   [256]uint8
+	classes int
+	// next[state*classes+class] - следующее состояние
+	next []int32
+	// out - самый длинный шаблон, заканчивающийся в состоянии, или -1
+	out []int32
+	// first - байты, с которых начинается хотя бы один шаблон, в обоих регистрах
+	first  [256]bool
+	maxLen int
+}
+
+func newPrefixMatcher(patterns []string) *prefixMatcher {
+	m := &prefixMatcher{patterns: patterns}
+
+	for _, p := range patterns {
+		for i := 0; i < len(p); i++ {
+			c := lowerASCII(p[i])
+			if m.class[c] == 0 {
+				m.classes++
+				m.class[c] = uint8(m.classes)
+			}
+		}
+		if len(p) > 0 {
+			m.first[lowerASCII(p[0])] = true
+			m.first[upperASCII(p[0])] = true
+		}
+		m.maxLen = max(m.maxLen, len(p))
+	}
+	for c := 'A'; c <= 'Z'; c++ {
+		m.class[c] = m.class[c+'a'-'A']
+	}
+	m.classes++
+
+	// Бор: состояние 0 - корень, -1 в таблице - перехода еще нет
+	m.addState()
+	for index, p := range patterns {
+		state := int32(0)
+		for i := 0; i < len(p); i++ {
+			slot := int(state)*m.classes + int(m.class[p[i]])
+			if m.next[slot] < 0 {
+				m.next[slot] = m.addState()
+			}
+			state = m.next[slot]
+		}
+		// Из одинаковых шаблонов остается первый
+		if len(p) > 0 && m.out[state] < 0 {
+			m.out[state] = int32(index)
+		}
+	}
+
+	// Обход в ширину: недостающие переходы берутся у failure-состояния,
+	// а совпадение - у него же, если у самого состояния своего нет
+	fail := make([]int32, len(m.out))
+	queue := make([]int32, 0, len(m.out))
+	for c := 0; c < m.classes; c++ {
+		if child := m.next[c]; child > 0 {
+			queue = append(queue, child)
+		} else {
+			m.next[c] = 0
+		}
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		if m.out[state] < 0 {
+			m.out[state] = m.out[fail[state]]
+		}
+		for c := 0; c < m.classes; c++ {
+			slot := int(state)*m.classes + c
+			fallback := m.next[int(fail[state])*m.classes+c]
+			if child := m.next[slot]; child > 0 {
+				fail[child] = fallback
+				queue = append(queue, child)
+			} else {
+				m.next[slot] = fallback
+			}
+		}
+	}
+	return m
+}
+
+func (m *prefixMatcher) addState() int32 {
+	for range m.classes {
+		m.next = append(m.next, -1)
+	}
+	m.out = append(m.out, -1)
+	return int32(len(m.out) - 1)
+}
+
+// find ищет самое левое вхождение шаблона в line[from:], при равном начале -
+// самое длинное. start == -1 - вхождений нет.
+func (m *prefixMatcher) find(line string, from int) (start, pattern int) {
+	start, pattern = -1, -1
+	state := int32(0)
+	for i := from; i < len(line); i++ {
+		// Более левое вхождение уже не найдется: оно закончилось бы раньше
+		if start >= 0 && i-start >= m.maxLen {
+			break
+		}
+		c := line[i]
+		if state == 0 && !m.first[c] {
+			continue
+		}
+		state = m.next[int(state)*m.classes+int(m.class[c])]
+
+		found := m.out[state]
+		if found < 0 {
+			continue
+		}
+		if s := i + 1 - len(m.patterns[found]); start < 0 || s <= start {
+			start, pattern = s, int(found)
+		}
+	}
+	return start, pattern
+}
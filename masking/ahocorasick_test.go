@@ -0,0 +1,146 @@
+package masking
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// naiveFind - перебор всех позиций и шаблонов, эталон для prefixMatcher
+func naiveFind(patterns []string, line string, from int) (start, pattern int) {
+	for i := from; i < len(line); i++ {
+		pattern = -1
+		for index, p := range patterns {
+			if p == "" || len(line)-i < len(p) || !equalFoldASCII(line[i:i+len(p)], p) {
+				continue
+			}
+			if pattern < 0 || len(p) > len(patterns[pattern]) {
+				pattern = index
+			}
+		}
+		if pattern >= 0 {
+			return i, pattern
+		}
+	}
+	return -1, -1
+}
+
+func equalFoldASCII(a, b string) bool {
+	for i := 0; i < len(a); i++ {
+		if lowerASCII(a[i]) != lowerASCII(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestPrefixMatcher(t *testing.T) {
+	m := newPrefixMatcher([]string{"http://", "https://", "ftp://", "tp:", "he", "she", "hers"})
+
+	tests := []struct {
+		line    string
+		from    int
+		start   int
+		pattern string
+	}{
+		{"без ссылок", 0, -1, ""},
+		{"see FTP://x", 0, 4, "ftp://"},
+		{"ushers", 0, 1, "she"},
+		{"hers", 0, 0, "hers"},
+		{"xHtTpS://a", 0, 1, "https://"},
+		{"http://a https://b", 1, 2, "tp:"},
+		{"http://a https://b", 7, 9, "https://"},
+	}
+	for _, tt := range tests {
+		start, pattern := m.find(tt.line, tt.from)
+		assert.Equal(t, tt.start, start, tt.line)
+		if tt.start >= 0 {
+			assert.Equal(t, tt.pattern, m.patterns[pattern], tt.line)
+		}
+	}
+
+	empty := newPrefixMatcher(nil)
+	start, _ := empty.find("http://a", 0)
+	assert.Equal(t, -1, start)
+}
+
+func TestPrefixMatcher_SameAsNaive(t *testing.T) {
+	r := rand.New(rand.NewPCG(3, 4))
+	alphabet := "abAB:/я"
+	word := func(n int) string {
+		var b strings.Builder
+		for range n {
+			b.WriteByte(alphabet[r.IntN(len(alphabet))])
+		}
+		return b.String()
+	}
+
+	for range 300 {
+		patterns := make([]string, 1+r.IntN(8))
+		for i := range patterns {
+			patterns[i] = word(1 + r.IntN(5))
+		}
+		m := newPrefixMatcher(patterns)
+		for range 20 {
+			line := word(r.IntN(30))
+			from := r.IntN(len(line) + 1)
+
+			start, pattern := m.find(line, from)
+			wantStart, wantPattern := naiveFind(patterns, line, from)
+			require.Equal(t, wantStart, start, "%q в %q с %d", patterns, line, from)
+			if wantStart >= 0 {
+				require.Equal(t, len(patterns[wantPattern]), len(patterns[pattern]), "%q в %q", patterns, line)
+			}
+		}
+	}
+}
+
+func TestLinkRule_Schemes(t *testing.T) {
+	masker := NewMasker()
+	masker.AddRule(NewLinkRule("ftp://", "ssh://", "git+ssh://"), StarsStrategy{})
+
+	assert.Equal(t, "ftp://*** и git+ssh://**** http://a",
+		masker.Mask("ftp://a.b и git+ssh://host http://a"))
+}
+
+// benchSchemes - n различных схем, среди которых есть http:// и https://
+func benchSchemes(n int) []string {
+	schemes := []string{"http://", "https://"}
+	for i := len(schemes); i < n; i++ {
+		schemes = append(schemes, fmt.Sprintf("scheme%d://", i))
+	}
+	return schemes
+}
+
+func BenchmarkLinkRule_Patterns(b *testing.B) {
+	line := strings.Repeat("текст со schema и ссылкой http://link.com ", 25)
+	for _, n := range []int{2, 50, 500} {
+		rule := NewLinkRule(benchSchemes(n)...)
+		b.Run(fmt.Sprintf("automaton/%d", n), func(b *testing.B) {
+			b.SetBytes(int64(len(line)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				rule.Find(line)
+			}
+		})
+	}
+	for _, n := range []int{2, 50, 500} {
+		schemes := benchSchemes(n)
+		b.Run(fmt.Sprintf("naive/%d", n), func(b *testing.B) {
+			b.SetBytes(int64(len(line)))
+			for i := 0; i < b.N; i++ {
+				for from := 0; ; {
+					start, pattern := naiveFind(schemes, line, from)
+					if start < 0 {
+						break
+					}
+					from = linkEnd(line, start+len(schemes[pattern]))
+				}
+			}
+		})
+	}
+}
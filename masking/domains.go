@@ -0,0 +1,195 @@
+package masking
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DomainFilter - списки доменов, ссылки на которые не маскируются (allow),
+// и исключений из них (deny). Запрещенный домен маскируется, даже если он
+// подходит под разрешающий шаблон: allow *.example.com, deny admin.example.com.
+// Домены, не попавшие ни в один список, маскируются как обычно.
+//
+// Шаблоны и домены сравниваются без учета регистра и точки в конце,
+// Unicode и punycode считаются одним доменом (пример.рф = xn--e1afmkfd.xn--p1ai):
+//   - example.com - только сам домен;
+//   - *.example.com - поддомены любой вложенности, но не сам example.com;
+//   - .example.com - сам домен и все его поддомены;
+//   - status-*.example.com - '*' заменяет любую последовательность символов.
+type DomainFilter struct {
+	allow []string
+	deny  []string
+}
+
+// NewDomainFilter проверяет шаблоны и приводит их к виду для сравнения
+func NewDomainFilter(allow, deny []string) (*DomainFilter, error) {
+	f := &DomainFilter{}
+	var err error
+	if f.allow, err = normalizeDomainPatterns(allow); err != nil {
+		return nil, err
+	}
+	if f.deny, err = normalizeDomainPatterns(deny); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func normalizeDomainPatterns(patterns []string) ([]string, error) {
+	result := make([]string, 0, len(patterns))
+	for _, p := range patterns {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if _, err := path.Match(p, ""); err != nil || strings.ContainsAny(p, "/@ ") {
+			return nil, fmt.Errorf("некорректный шаблон домена %q", p)
+		}
+		result = append(result, canonicalPattern(p))
+	}
+	return result, nil
+}
+
+// canonicalPattern переводит в punycode метки шаблона без '*'
+func canonicalPattern(p string) string {
+	labels := strings.Split(normalizeHost(p), ".")
+	for i, label := range labels {
+		if !strings.Contains(label, "*") {
+			labels[i] = canonicalHost(label)
+		}
+	}
+	return strings.Join(labels, ".")
+}
+
+// LoadDomainList читает шаблоны доменов из файла: по одному на строку,
+// пустые строки и комментарии после '#' пропускаются
+func LoadDomainList(name string) ([]string, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var patterns []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		if line = strings.TrimSpace(line); line != "" {
+			patterns = append(patterns, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка чтения списка доменов %s: %w", name, err)
+	}
+	return patterns, nil
+}
+
+// Allowed - можно ли оставить ссылку на host открытой
+func (f *DomainFilter) Allowed(host string) bool {
+	if f == nil || host == "" {
+		return false
+	}
+	host = canonicalHost(host)
+	return matchDomain(f.allow, host) && !matchDomain(f.deny, host)
+}
+
+// Empty - в фильтре нет ни одного шаблона
+func (f *DomainFilter) Empty() bool {
+	return f == nil || len(f.allow)+len(f.deny) == 0
+}
+
+// String - описание шаблонов, например для сравнения настроек двух запусков
+func (f *DomainFilter) String() string {
+	if f == nil {
+		return ""
+	}
+	return "allow=" + strings.Join(f.allow, ",") + ";deny=" + strings.Join(f.deny, ",")
+}
+
+func matchDomain(patterns []string, host string) bool {
+	for _, p := range patterns {
+		switch {
+		case strings.HasPrefix(p, "*.") && !strings.Contains(p[2:], "*"):
+			if strings.HasSuffix(host, p[1:]) {
+				return true
+			}
+		case strings.HasPrefix(p, "."):
+			if host == p[1:] || strings.HasSuffix(host, p) {
+				return true
+			}
+		case strings.Contains(p, "*"):
+			if ok, _ := path.Match(p, host); ok {
+				return true
+			}
+		case host == p:
+			return true
+		}
+	}
+	return false
+}
+
+// linkHost выделяет домен из ссылки: схема уже отрезана (keep), дальше
+// отбрасываются логин, порт, путь и знаки препинания после домена.
+// Если за доменом идет что-то другое, возвращается пустая строка: ссылка
+// тянется до пробела, и в "http://github.com,http://intranet.corp/x" или
+// "http://github.com%2eevil.com" открытый домен скрыл бы остальное.
+func linkHost(value string, keep int) string {
+	start, end := linkHostBounds(value, keep)
+	if !hostEndsLink(value[end:]) {
+		return ""
+	}
+	return strings.TrimRight(value[start:end], ".")
+}
+
+// hostEndsLink - можно ли считать ссылку ссылкой на домен, за которым идет rest:
+// дальше порт, путь, запрос, фрагмент или только знаки препинания (скобка,
+// кавычка в конце), но не другая ссылка
+func hostEndsLink(rest string) bool {
+	if rest == "" {
+		return true
+	}
+	if strings.Contains(rest, "://") {
+		return false
+	}
+	if strings.IndexByte("/:?#", rest[0]) >= 0 {
+		return true
+	}
+	return strings.IndexFunc(rest, func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '%'
+	}) < 0
+}
+
+// linkHostBounds - границы домена внутри value вместе с точкой в конце, если она есть
+func linkHostBounds(value string, keep int) (start, end int) {
+	start = min(keep, len(value))
+	authority := value[start:]
+	if i := strings.IndexAny(authority, "/?#"); i >= 0 {
+		authority = authority[:i]
+	}
+	if i := strings.LastIndexByte(authority, '@'); i >= 0 {
+		start += i + 1
+	}
+
+	end = start
+	for end < len(value) {
+		c := value[end]
+		if c < utf8.RuneSelf {
+			if c == '.' || c == '-' || c == '_' || isDigit(c) || ('a' <= lowerASCII(c) && lowerASCII(c) <= 'z') {
+				end++
+				continue
+			}
+			break
+		}
+		// Кавычки-елочки и прочая пунктуация после домена в него не входят
+		r, size := utf8.DecodeRuneInString(value[end:])
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsMark(r) {
+			break
+		}
+		end += size
+	}
+	return start, end
+}
@@ -0,0 +1,45 @@
+package masking
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// Домены в ссылках встречаются и в Unicode (пример.рф), и в punycode
+// (xn--e1afmkfd.xn--p1ai). Для сравнения со списками доменов оба вида
+// приводятся к одному: нижний регистр, без точки в конце, метки с
+// не-ASCII символами - в punycode. Преобразование - по правилам IDNA для
+// поиска домена (idna.Lookup), как в браузерах.
+
+// HostToASCII приводит домен к виду punycode: "Пример.РФ." -> "xn--e1afmkfd.xn--p1ai"
+func HostToASCII(host string) (string, error) {
+	ascii, err := idna.Lookup.ToASCII(normalizeHost(host))
+	if err != nil {
+		return "", fmt.Errorf("домен %q: %w", host, err)
+	}
+	return ascii, nil
+}
+
+// HostToUnicode приводит домен к виду Unicode: "XN--E1AFMKFD.xn--p1ai" -> "пример.рф"
+func HostToUnicode(host string) (string, error) {
+	decoded, err := idna.Lookup.ToUnicode(normalizeHost(host))
+	if err != nil {
+		return "", fmt.Errorf("домен %q: %w", host, err)
+	}
+	return decoded, nil
+}
+
+// canonicalHost - вид домена для сравнения: punycode, а если домен
+// не кодируется - просто нижний регистр без точки в конце
+func canonicalHost(host string) string {
+	if ascii, err := HostToASCII(host); err == nil {
+		return ascii
+	}
+	return normalizeHost(host)
+}
+
+func normalizeHost(host string) string {
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
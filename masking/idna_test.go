@@ -0,0 +1,93 @@
+package masking
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHostConversion(t *testing.T) {
+	ascii, err := HostToASCII("WWW.Пример.РФ.")
+	require.NoError(t, err)
+	assert.Equal(t, "www.xn--e1afmkfd.xn--p1ai", ascii)
+
+	unicode, err := HostToUnicode("WWW.XN--E1AFMKFD.xn--p1ai.")
+	require.NoError(t, err)
+	assert.Equal(t, "www.пример.рф", unicode)
+
+	// Примеры из RFC 3492 и реальные домены
+	tests := []struct {
+		unicode  string
+		punycode string
+	}{
+		{"испытание.рф", "xn--80akhbyknj4f.xn--p1ai"},
+		{"bücher.de", "xn--bcher-kva.de"},
+		{"почемужеонинеговорятпорусски.ru", "xn--b1abfaaepdrnnbgefbadotcwatmq2g4l.ru"},
+		{"他们为什么不说中文.cn", "xn--ihqwcrb4cv8a8dqg056pqjye.cn"},
+	}
+	for _, tt := range tests {
+		ascii, err := HostToASCII(tt.unicode)
+		require.NoError(t, err)
+		assert.Equal(t, tt.punycode, ascii)
+
+		decoded, err := HostToUnicode(tt.punycode)
+		require.NoError(t, err)
+		assert.Equal(t, tt.unicode, decoded)
+	}
+
+	_, err = HostToUnicode("xn--a.ru")
+	assert.Error(t, err)
+	assert.Equal(t, "xn--a.ru", canonicalHost("XN--A.ru."), "некорректный punycode сравнивается как есть")
+	assert.Equal(t, "sub_domain.example.com", canonicalHost("Sub_Domain.example.com"),
+		"домен, который IDNA не допускает, сравнивается как есть")
+}
+
+func TestDomainFilter_IDN(t *testing.T) {
+	filter, err := NewDomainFilter([]string{"пример.рф", "*.xn--d1acufc.xn--p1ai"}, nil)
+	require.NoError(t, err)
+
+	for _, host := range []string{"пример.рф", "ПРИМЕР.РФ.", "xn--e1afmkfd.xn--p1ai", "почта.домен.рф", "mail.xn--d1acufc.xn--p1ai"} {
+		assert.True(t, filter.Allowed(host), host)
+	}
+	assert.False(t, filter.Allowed("другой.рф"))
+
+	masker := NewLinkMasker()
+	require.NoError(t, masker.SetDomainFilter("link", filter))
+	masked, allowed := masker.MaskAllowed("«http://пример.рф/путь» и http://xn--e1afmkfd.xn--p1ai и http://другой.рф")
+	assert.Equal(t, "«http://пример.рф/путь» и http://xn--e1afmkfd.xn--p1ai и http://*********", masked)
+	assert.Equal(t, 2, allowed)
+}
+
+func TestDomainStrategy(t *testing.T) {
+	tests := []struct {
+		name     string
+		form     HostForm
+		input    string
+		expected string
+	}{
+		{"как есть", HostAsIs, "см. https://u:p@Пример.РФ:8080/путь?q=1.", "см. https://****Пример.РФ***************"},
+		{"в Unicode", HostUnicode, "см. http://XN--E1AFMKFD.xn--p1ai/x", "см. http://пример.рф**"},
+		{"в punycode", HostASCII, "см. http://Пример.рф/путь", "см. http://xn--e1afmkfd.xn--p1ai*****"},
+		{"точка в конце предложения", HostASCII, "сайт http://пример.рф.", "сайт http://xn--e1afmkfd.xn--p1ai."},
+		{"кавычки после домена", HostAsIs, "«http://пример.рф»", "«http://пример.рф*"},
+		{"без домена", HostAsIs, "http://", "http://"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			masker := NewMasker()
+			masker.AddRule(NewLinkRule(), DomainStrategy{Form: tt.form})
+			assert.Equal(t, tt.expected, masker.Mask(tt.input))
+		})
+	}
+
+	phone := NewMasker()
+	rule, err := NewPhoneRule()
+	require.NoError(t, err)
+	phone.AddRule(rule, DomainStrategy{})
+	assert.Equal(t, "тел. ************", phone.Mask("тел. +79123456789"), "без домена - как stars")
+
+	strategy, err := StrategyByName("domain-unicode")
+	require.NoError(t, err)
+	assert.Equal(t, DomainStrategy{Form: HostUnicode}, strategy)
+}
@@ -0,0 +1,309 @@
+// Package masking - движок маскировки: правила поиска чувствительных данных
+// (ссылки, телефоны, номера карт) и стратегии их замены. Пакет не зависит
+// от CLI и форматов файлов, его можно подключать в другие сервисы.
+// Для потоков есть обертки NewMaskingReader и NewMaskingWriter:
+//
+//	w := masking.NewMaskingWriter(file, masking.WithMasker(masker))
+//	defer w.Close()
+//	io.Copy(w, upload)
+package masking
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Match - фрагмент строки, найденный правилом (байтовые смещения)
+type Match struct {
+	Start int
+	End   int
+	// Keep - сколько байт от начала фрагмента остаются открытыми (например схема ссылки)
+	Keep int
+	Rule string
+
+	entry int // индекс правила в Masker, заполняется в Masker.Find
+}
+
+// Rule - детектор чувствительных данных в строке
+type Rule interface {
+	Name() string
+	Find(line string) []Match
+}
+
+// Strategy - способ замены найденного фрагмента
+type Strategy interface {
+	Replace(value string, m Match) string
+}
+
+type ruleEntry struct {
+	rule     Rule
+	strategy Strategy
+	// filter - домены, совпадения с которыми не маскируются (только для ссылок)
+	filter *DomainFilter
+}
+
+// Masker - движок маскировки: прогоняет строку через набор правил
+// и заменяет найденные фрагменты по стратегии каждого правила.
+// После настройки используется воркерами только на чтение.
+type Masker struct {
+	entries []ruleEntry
+}
+
+func NewMasker() *Masker {
+	return &Masker{}
+}
+
+// NewLinkMasker - движок с единственным правилом ссылок, как у maskLink
+func NewLinkMasker() *Masker {
+	m := NewMasker()
+	m.AddRule(NewLinkRule(), StarsStrategy{})
+	return m
+}
+
+func (m *Masker) AddRule(rule Rule, strategy Strategy) {
+	if strategy == nil {
+		strategy = StarsStrategy{}
+	}
+	m.entries = append(m.entries, ruleEntry{rule: rule, strategy: strategy})
+}
+
+// SetDomainFilter задает списки доменов для правила с именем rule. Проверка
+// выполняется до стратегии замены: совпадение с разрешенным доменом
+// остается в строке как есть.
+func (m *Masker) SetDomainFilter(rule string, filter *DomainFilter) error {
+	for i := range m.entries {
+		if m.entries[i].rule.Name() == rule {
+			m.entries[i].filter = filter
+			return nil
+		}
+	}
+	return fmt.Errorf("правило %q не подключено", rule)
+}
+
+// DomainFilter возвращает списки доменов правила rule, nil - если их нет
+func (m *Masker) DomainFilter(rule string) *DomainFilter {
+	for _, e := range m.entries {
+		if e.rule.Name() == rule {
+			return e.filter
+		}
+	}
+	return nil
+}
+
+func (m *Masker) RuleNames() []string {
+	names := make([]string, 0, len(m.entries))
+	for _, e := range m.entries {
+		names = append(names, e.rule.Name())
+	}
+	return names
+}
+
+// Ordered - результат зависит от порядка обработки строк (PlaceholderStrategy
+// нумерует значения по первому появлению). Тогда строки нужно заменять
+// через Replace по порядку входных данных, а параллельно только искать.
+func (m *Masker) Ordered() bool {
+	for _, e := range m.entries {
+		if _, ok := e.strategy.(*PlaceholderStrategy); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Placeholders - заглушки, выданные всеми PlaceholderStrategy движка, в порядке выдачи
+func (m *Masker) Placeholders() []PlaceholderEntry {
+	var result []PlaceholderEntry
+	seen := make(map[*PlaceholderStrategy]bool)
+	for _, e := range m.entries {
+		strategy, ok := e.strategy.(*PlaceholderStrategy)
+		if !ok || seen[strategy] {
+			continue
+		}
+		seen[strategy] = true
+		result = append(result, strategy.Entries()...)
+	}
+	return result
+}
+
+// Find возвращает непересекающиеся совпадения всех правил, отсортированные
+// по позиции. Совпадения с разрешенными доменами (SetDomainFilter) не возвращаются.
+func (m *Masker) Find(line string) []Match {
+	matches, _ := m.FindAllowed(line)
+	return matches
+}
+
+// FindAllowed - Find вместе с числом совпадений, пропущенных по спискам доменов
+func (m *Masker) FindAllowed(line string) ([]Match, int) {
+	return m.dropAllowed(line, m.overlapping(line))
+}
+
+// dropAllowed убирает из matches (на месте) совпадения с разрешенными доменами
+func (m *Masker) dropAllowed(line string, matches []Match) ([]Match, int) {
+	if len(matches) == 0 {
+		return matches, 0
+	}
+
+	// Домены проверяются после разрешения пересечений: внутри разрешенной
+	// ссылки не маскируется и то, что нашли другие правила
+	allowed := 0
+	result := matches[:0]
+	for _, match := range matches {
+		e := m.entries[match.entry]
+		if !e.filter.Empty() && e.filter.Allowed(linkHost(line[match.Start:match.End], match.Keep)) {
+			allowed++
+			continue
+		}
+		result = append(result, match)
+	}
+	return result, allowed
+}
+
+// overlapping собирает совпадения всех правил и убирает пересекающиеся
+func (m *Masker) overlapping(line string) []Match {
+	var matches []Match
+	for i, e := range m.entries {
+		found := e.rule.Find(line)
+		if matches == nil {
+			// Срез первого правила с совпадениями используется как есть, без копирования
+			for j := range found {
+				found[j].entry = i
+			}
+			matches = found
+			continue
+		}
+		for _, match := range found {
+			match.entry = i
+			matches = append(matches, match)
+		}
+	}
+	if len(matches) < 2 {
+		return matches
+	}
+
+	// При пересечении побеждает более раннее, а при равном начале - более длинное совпадение
+	slices.SortStableFunc(matches, func(a, b Match) int {
+		if a.Start != b.Start {
+			return a.Start - b.Start
+		}
+		return b.End - a.End
+	})
+
+	result := matches[:1]
+	for _, match := range matches[1:] {
+		if match.Start < result[len(result)-1].End {
+			continue
+		}
+		result = append(result, match)
+	}
+	return result
+}
+
+// Mask заменяет найденные фрагменты. Строка без совпадений возвращается
+// как есть без выделения памяти, StarsStrategy пишет замену прямо в результат.
+func (m *Masker) Mask(line string) string {
+	masked, _ := m.MaskAllowed(line)
+	return masked
+}
+
+// MaskAllowed - Mask, который дополнительно возвращает, сколько совпадений
+// осталось открытыми по спискам доменов (для отчетов)
+func (m *Masker) MaskAllowed(line string) (string, int) {
+	matches, allowed := m.FindAllowed(line)
+	return m.Replace(line, matches), allowed
+}
+
+// Replace заменяет в line совпадения, найденные этим же движком (Find, FindAllowed)
+func (m *Masker) Replace(line string, matches []Match) string {
+	if len(matches) == 0 {
+		return line
+	}
+
+	var b strings.Builder
+	b.Grow(len(line))
+	prev := 0
+	for _, match := range matches {
+		b.WriteString(line[prev:match.Start])
+		value := line[match.Start:match.End]
+		switch strategy := m.entries[match.entry].strategy.(type) {
+		case StarsStrategy:
+			// Конкретный тип, а не интерфейс: так b не уходит в кучу
+			strategy.writeReplace(&b, value, match)
+		default:
+			b.WriteString(strategy.Replace(value, match))
+		}
+		prev = match.End
+	}
+	b.WriteString(line[prev:])
+	return b.String()
+}
+
+// Masked - замаскирован ли уже фрагмент match строки line: повторная замена
+// его не меняет. Стратегия с состоянием (PlaceholderStrategy) не вызывается, чтобы
+// проверка не выдавала новых номеров: ее заглушки правилами не находятся, поэтому
+// найденное значение для нее всегда открыто.
+func (m *Masker) Masked(line string, match Match) bool {
+	strategy := m.entries[match.entry].strategy
+	if _, ok := strategy.(*PlaceholderStrategy); ok {
+		return false
+	}
+	value := line[match.Start:match.End]
+	return strategy.Replace(value, match) == value
+}
+
+// MaskLinks маскирует ссылки http(s) так же, как CLI с правилами по умолчанию
+func MaskLinks(line string) string {
+	return linkMasker.Mask(line)
+}
+
+var linkMasker = NewLinkMasker()
+
+// ParseRules собирает движок из описания вида "link,phone:last4,card".
+// Стратегия после двоеточия перекрывает defaultStrategy для конкретного правила.
+func ParseRules(spec, defaultStrategy string, phoneCountries []string) (*Masker, error) {
+	m := NewMasker()
+	seen := make(map[string]bool)
+
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		name, strategyName, found := strings.Cut(item, ":")
+		if !found {
+			strategyName = defaultStrategy
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		if seen[name] {
+			return nil, fmt.Errorf("правило %q указано дважды", name)
+		}
+		seen[name] = true
+
+		strategy, err := StrategyByName(strings.TrimSpace(strategyName))
+		if err != nil {
+			return nil, err
+		}
+
+		var rule Rule
+		switch name {
+		case "link":
+			rule = NewLinkRule()
+		case "phone":
+			rule, err = NewPhoneRule(phoneCountries...)
+		case "card":
+			rule = NewCardRule()
+		default:
+			return nil, fmt.Errorf("неизвестное правило: %q", name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		m.AddRule(rule, strategy)
+	}
+
+	if len(m.entries) == 0 {
+		return nil, fmt.Errorf("не указано ни одного правила маскировки")
+	}
+	return m, nil
+}
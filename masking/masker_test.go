@@ -0,0 +1,43 @@
+package masking
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaskLinks(t *testing.T) {
+	assert.Equal(t, "see http://**** and HTTPS://*****", MaskLinks("see http://a.io and HTTPS://b.io/"))
+	assert.Equal(t, "нет ссылок", MaskLinks("нет ссылок"))
+}
+
+func TestMask_Allocations(t *testing.T) {
+	masker := NewLinkMasker()
+
+	noLinks := "обычная строка лога без ссылок"
+	assert.Zero(t, testing.AllocsPerRun(100, func() {
+		masker.Mask(noLinks)
+	}), "строка без совпадений не копируется")
+
+	// Срез совпадений и результат - больше ничего
+	withLink := "запрос http://example.com/path завершен"
+	assert.LessOrEqual(t, testing.AllocsPerRun(100, func() {
+		masker.Mask(withLink)
+	}), float64(2))
+}
+
+func BenchmarkMask(b *testing.B) {
+	masker := NewLinkMasker()
+	line := "GET http://example.com/api/v1/items?id=42 200 12ms"
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		masker.Mask(line)
+	}
+}
+
+func TestLastFourStrategy_Multibyte(t *testing.T) {
+	value := "+7 912 345-67-89 доб. 12"
+	assert.Equal(t, "+* *** ***-**-89 ***. 12",
+		LastFourStrategy{}.Replace(value, Match{End: len(value)}))
+	assert.Equal(t, "tel:** 5678", LastFourStrategy{}.Replace("tel:12 5678", Match{Keep: 4}))
+}
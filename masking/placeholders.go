@@ -0,0 +1,73 @@
+package masking
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// PlaceholderStrategy - одинаковые значения заменяются одной и той же
+// нумерованной заглушкой: первая уникальная ссылка - [LINK-1], вторая -
+// [LINK-2] и т.д., у каждого правила своя нумерация. Так в тексте видно,
+// что ссылка во втором абзаце та же, что и в девятом.
+//
+// Номера выдаются в порядке вызовов Replace, стратегию безопасно вызывать
+// из нескольких горутин. Чтобы нумерация зависела только от входных данных,
+// а не от скорости воркеров, Service.Run для такого движка ищет совпадения
+// параллельно, а заменяет по порядку строк (см. Masker.Ordered).
+type PlaceholderStrategy struct {
+	mu      sync.Mutex
+	numbers map[placeholderKey]int
+	counts  map[string]int
+	entries []PlaceholderEntry
+}
+
+// PlaceholderEntry - выданная заглушка и значение, которое она скрывает
+type PlaceholderEntry struct {
+	Placeholder string `json:"placeholder"`
+	Rule        string `json:"rule"`
+	Value       string `json:"value"`
+}
+
+type placeholderKey struct {
+	rule  string
+	value string
+}
+
+// placeholderPrefixes - префиксы заглушек встроенных правил, для остальных - имя в верхнем регистре
+var placeholderPrefixes = map[string]string{"link": "LINK", "phone": "PHONE", "card": "CARD"}
+
+func NewPlaceholderStrategy() *PlaceholderStrategy {
+	return &PlaceholderStrategy{
+		numbers: make(map[placeholderKey]int),
+		counts:  make(map[string]int),
+	}
+}
+
+func (s *PlaceholderStrategy) Replace(value string, m Match) string {
+	key := placeholderKey{rule: m.Rule, value: value}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if index, ok := s.numbers[key]; ok {
+		return s.entries[index].Placeholder
+	}
+
+	prefix, ok := placeholderPrefixes[m.Rule]
+	if !ok {
+		prefix = strings.ToUpper(m.Rule)
+	}
+	s.counts[m.Rule]++
+	placeholder := "[" + prefix + "-" + strconv.Itoa(s.counts[m.Rule]) + "]"
+
+	s.numbers[key] = len(s.entries)
+	s.entries = append(s.entries, PlaceholderEntry{Placeholder: placeholder, Rule: m.Rule, Value: value})
+	return placeholder
+}
+
+// Entries - выданные заглушки в порядке выдачи
+func (s *PlaceholderStrategy) Entries() []PlaceholderEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]PlaceholderEntry(nil), s.entries...)
+}
@@ -0,0 +1,82 @@
+package masking
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlaceholderStrategy(t *testing.T) {
+	masker, err := ParseRules("link,phone", "numbered", nil)
+	require.NoError(t, err)
+	assert.True(t, masker.Ordered())
+	assert.False(t, NewLinkMasker().Ordered())
+
+	assert.Equal(t, "[LINK-1] и [LINK-2] звонить [PHONE-1]",
+		masker.Mask("http://a.io и https://b.io/x, звонить +79123456789"))
+	assert.Equal(t, "снова [LINK-2] потом [LINK-1] и [LINK-3]",
+		masker.Mask("снова https://b.io/x, потом http://a.io и http://c.io"))
+
+	assert.Equal(t, []PlaceholderEntry{
+		{Placeholder: "[LINK-1]", Rule: "link", Value: "http://a.io"},
+		{Placeholder: "[LINK-2]", Rule: "link", Value: "https://b.io/x,"},
+		{Placeholder: "[LINK-3]", Rule: "link", Value: "http://c.io"},
+		{Placeholder: "[PHONE-1]", Rule: "phone", Value: "+79123456789"},
+	}, masker.Placeholders())
+}
+
+func TestPlaceholderStrategy_Concurrent(t *testing.T) {
+	strategy := NewPlaceholderStrategy()
+	masker := NewMasker()
+	masker.AddRule(NewLinkRule(), strategy)
+
+	var wg sync.WaitGroup
+	for worker := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 100 {
+				masker.Mask(fmt.Sprintf("http://host%d.io", (i+worker*13)%50))
+			}
+		}()
+	}
+	wg.Wait()
+
+	entries := strategy.Entries()
+	require.Len(t, entries, 50, "каждое значение получает один номер")
+	seen := make(map[string]bool)
+	for i, entry := range entries {
+		assert.Equal(t, fmt.Sprintf("[LINK-%d]", i+1), entry.Placeholder)
+		assert.False(t, seen[entry.Value])
+		seen[entry.Value] = true
+	}
+}
+
+func TestMasker_Replace(t *testing.T) {
+	masker := NewLinkMasker()
+	line := "a http://x.io b"
+	matches, allowed := masker.FindAllowed(line)
+	assert.Zero(t, allowed)
+	assert.Equal(t, masker.Mask(line), masker.Replace(line, matches))
+	assert.Equal(t, "без ссылок", masker.Replace("без ссылок", nil))
+}
+
+func TestMasker_Masked(t *testing.T) {
+	masker := NewLinkMasker()
+	line := "http://****** и http://x.io"
+	matches := masker.Find(line)
+	require.Len(t, matches, 2)
+	assert.True(t, masker.Masked(line, matches[0]))
+	assert.False(t, masker.Masked(line, matches[1]))
+
+	// Проверка не выдает номеров заглушек
+	numbered, err := ParseRules("link", "numbered", nil)
+	require.NoError(t, err)
+	matches = numbered.Find(line)
+	require.Len(t, matches, 2)
+	assert.False(t, numbered.Masked(line, matches[1]))
+	assert.Empty(t, numbered.Placeholders())
+}
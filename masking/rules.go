@@ -0,0 +1,283 @@
+package masking
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// LinkRule - ссылки со схемой http(s) или другими заданными префиксами.
+// Ссылка продолжается до первого пробельного символа, сама схема остается открытой.
+//
+// Схемы ищутся автоматом Ахо-Корасик (см. prefixMatcher) без учета регистра
+// ASCII, поэтому стоимость просмотра не растет с числом схем. Многобайтовые
+// символы декодируются только для проверки на пробел. Строка без ссылок
+// не вызывает выделений памяти.
+type LinkRule struct {
+	matcher *prefixMatcher
+}
+
+// NewLinkRule создает правило для схем schemes, по умолчанию http:// и https://.
+// Пустые схемы пропускаются; из нескольких схем с общим началом
+// выбирается самая длинная.
+func NewLinkRule(schemes ...string) *LinkRule {
+	if len(schemes) == 0 {
+		schemes = []string{"http://", "https://"}
+	}
+	patterns := make([]string, 0, len(schemes))
+	for _, scheme := range schemes {
+		if scheme != "" {
+			patterns = append(patterns, scheme)
+		}
+	}
+	return &LinkRule{matcher: newPrefixMatcher(patterns)}
+}
+
+func (r *LinkRule) Name() string {
+	return "link"
+}
+
+func (r *LinkRule) Find(line string) []Match {
+	// Первый проход только считает ссылки, чтобы срез выделился один раз
+	count := 0
+	for start, end, _ := r.next(line, 0); start >= 0; start, end, _ = r.next(line, end) {
+		count++
+	}
+	if count == 0 {
+		return nil
+	}
+
+	matches := make([]Match, 0, count)
+	for start, end, keep := r.next(line, 0); start >= 0; start, end, keep = r.next(line, end) {
+		matches = append(matches, Match{Start: start, End: end, Keep: keep, Rule: "link"})
+	}
+	return matches
+}
+
+// next ищет ссылку, начиная с from; start == -1 - ссылок больше нет
+func (r *LinkRule) next(line string, from int) (start, end, keep int) {
+	start, pattern := r.matcher.find(line, from)
+	if start < 0 {
+		return -1, -1, 0
+	}
+	keep = len(r.matcher.patterns[pattern])
+	return start, linkEnd(line, start+keep), keep
+}
+
+// linkEnd - позиция первого пробельного символа (в смысле unicode.IsSpace) начиная с from
+func linkEnd(line string, from int) int {
+	end := from
+	for end < len(line) {
+		c := line[end]
+		if c < utf8.RuneSelf {
+			if asciiSpace[c] {
+				break
+			}
+			end++
+			continue
+		}
+		ch, size := utf8.DecodeRuneInString(line[end:])
+		if unicode.IsSpace(ch) {
+			break
+		}
+		end += size
+	}
+	return end
+}
+
+// asciiSpace - ASCII-символы, для которых unicode.IsSpace возвращает true
+var asciiSpace = [utf8.RuneSelf]bool{'\t': true, '\n': true, '\v': true, '\f': true, '\r': true, ' ': true}
+
+func lowerASCII(c byte) byte {
+	if 'A' <= c && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
+
+func upperASCII(c byte) byte {
+	if 'a' <= c && c <= 'z' {
+		return c - ('a' - 'A')
+	}
+	return c
+}
+
+// Шаблоны телефонов по странам. Разделители - пробел, дефис и скобки вокруг кода.
+var phonePatterns = map[string]string{
+	// Россия и Казахстан: +7 / 8, код из трех цифр
+	"ru": `(?:\+7|8)[\s-]?\(?\d{3}\)?[\s-]?\d{3}[\s-]?\d{2}[\s-]?\d{2}`,
+	"by": `\+375[\s-]?\(?\d{2}\)?[\s-]?\d{3}[\s-]?\d{2}[\s-]?\d{2}`,
+	"ua": `\+380[\s-]?\(?\d{2}\)?[\s-]?\d{3}[\s-]?\d{2}[\s-]?\d{2}`,
+	// Любой номер в формате E.164 без разделителей
+	"intl": `\+[1-9]\d{7,14}`,
+}
+
+// Страны с общим планом нумерации: код указывает на шаблон другой страны.
+// Казахстан входит в зону +7 вместе с Россией, поэтому "kz" - синоним "ru".
+var phoneAliases = map[string]string{
+	"kz": "ru",
+}
+
+// PhoneRule - телефонные номера по шаблонам выбранных стран
+type PhoneRule struct {
+	re *regexp.Regexp
+}
+
+func NewPhoneRule(countries ...string) (*PhoneRule, error) {
+	if len(countries) == 0 {
+		countries = []string{"ru"}
+	}
+
+	var parts []string
+	seen := make(map[string]bool)
+	for _, country := range countries {
+		code := strings.ToLower(strings.TrimSpace(country))
+		if alias, ok := phoneAliases[code]; ok {
+			code = alias
+		}
+		pattern, ok := phonePatterns[code]
+		if !ok {
+			return nil, fmt.Errorf("нет шаблона телефона для страны %q", country)
+		}
+		if seen[code] {
+			continue
+		}
+		seen[code] = true
+		parts = append(parts, pattern)
+	}
+
+	return &PhoneRule{re: regexp.MustCompile(strings.Join(parts, "|"))}, nil
+}
+
+func (r *PhoneRule) Name() string {
+	return "phone"
+}
+
+func (r *PhoneRule) Find(line string) []Match {
+	return findDigitBounded(r.re, line, r.Name())
+}
+
+var digitRunPattern = regexp.MustCompile(`\d+(?:[ -]\d+)*`)
+
+// CardRule - номера платежных карт (13-19 цифр), проверенные алгоритмом Луна,
+// чтобы не маскировать номера заказов и прочие длинные числа
+type CardRule struct{}
+
+func NewCardRule() *CardRule {
+	return &CardRule{}
+}
+
+func (r *CardRule) Name() string {
+	return "card"
+}
+
+// Find разбивает каждую последовательность цифр на группы по разделителям
+// и ищет в ней самый длинный непрерывный набор групп, похожий на номер карты.
+// Окно от каждой группы расширяется, пока в нем не больше 19 цифр, а число цифр
+// и суммы Луна накапливаются по ходу, поэтому длинные числовые строки не тормозят.
+func (r *CardRule) Find(line string) []Match {
+	if !strings.ContainsAny(line, "0123456789") {
+		return nil
+	}
+
+	var matches []Match
+	for _, loc := range digitRunPattern.FindAllStringIndex(line, -1) {
+		groups := digitGroups(line, loc[0], loc[1])
+
+		for first := 0; first < len(groups); first++ {
+			// sums[p] - сумма Луна, если удваиваются цифры с четностью p от начала окна
+			var sums [2]int
+			digits := 0
+			best := -1
+			for last := first; last < len(groups) && digits <= 19; last++ {
+				for i := groups[last][0]; i < groups[last][1] && digits <= 19; i++ {
+					d := int(line[i] - '0')
+					doubled := d * 2
+					if doubled > 9 {
+						doubled -= 9
+					}
+					sums[digits%2] += doubled
+					sums[1-digits%2] += d
+					digits++
+				}
+				// Последняя цифра не удваивается: удваиваются цифры другой четности
+				if digits >= 13 && digits <= 19 && sums[digits%2]%10 == 0 {
+					best = last
+				}
+			}
+			if best < 0 {
+				continue
+			}
+			matches = append(matches, Match{Start: groups[first][0], End: groups[best][1], Rule: r.Name()})
+			first = best
+		}
+	}
+	return matches
+}
+
+// digitGroups - границы групп цифр внутри [start, end), разделенных пробелом или дефисом
+func digitGroups(line string, start, end int) [][2]int {
+	var groups [][2]int
+	groupStart := start
+	for i := start; i < end; i++ {
+		if !isDigit(line[i]) {
+			groups = append(groups, [2]int{groupStart, i})
+			groupStart = i + 1
+		}
+	}
+	return append(groups, [2]int{groupStart, end})
+}
+
+// findDigitBounded ищет совпадения, не примыкающие к другим цифрам
+// (в RE2 нет lookbehind, поэтому границы проверяются вручную)
+func findDigitBounded(re *regexp.Regexp, line, rule string) []Match {
+	if !strings.ContainsAny(line, "0123456789") {
+		return nil
+	}
+
+	var matches []Match
+	for pos := 0; pos < len(line); {
+		loc := re.FindStringIndex(line[pos:])
+		if loc == nil {
+			break
+		}
+		start, end := pos+loc[0], pos+loc[1]
+		if (start > 0 && isDigit(line[start-1])) || (end < len(line) && isDigit(line[end])) {
+			// Совпадение внутри длинного числа - пробуем со следующего символа
+			pos = start + 1
+			continue
+		}
+		matches = append(matches, Match{Start: start, End: end, Rule: rule})
+		pos = end
+	}
+	return matches
+}
+
+func isDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
+
+// luhnValid - контрольная сумма по алгоритму Луна, разделители игнорируются
+func luhnValid(number string) bool {
+	sum := 0
+	double := false
+	digits := 0
+	for i := len(number) - 1; i >= 0; i-- {
+		if !isDigit(number[i]) {
+			continue
+		}
+		d := int(number[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+		digits++
+	}
+	return digits > 0 && sum%10 == 0
+}
@@ -0,0 +1,246 @@
+package masking
+
+import (
+	"math/rand/v2"
+	"strings"
+	"testing"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPhoneRule(t *testing.T) {
+	rule, err := NewPhoneRule("ru", "by")
+	require.NoError(t, err)
+
+	masker := NewMasker()
+	masker.AddRule(rule, LastFourStrategy{})
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"+7 с разделителями", "звоните +7 (912) 345-67-89", "звоните +* (***) ***-67-89"},
+		{"+7 без разделителей", "тел.+79123456789.", "тел.+*******6789."},
+		{"через восьмерку", "8 912 345 67 89 до 18:00", "* *** *** 67 89 до 18:00"},
+		{"белорусский номер", "+375 29 123-45-67", "+*** ** ***-45-67"},
+		{"часть длинного числа", "заказ 1289123456789001", "заказ 1289123456789001"},
+		{"без номеров", "просто текст", "просто текст"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, masker.Mask(test.input))
+		})
+	}
+
+	_, err = NewPhoneRule("xx")
+	assert.Error(t, err)
+}
+
+func TestPhoneRuleAlias(t *testing.T) {
+	ru, err := NewPhoneRule("ru")
+	require.NoError(t, err)
+
+	// kz - синоним ru и не дублирует его шаблон
+	for _, countries := range [][]string{{"kz"}, {"ru", "kz"}, {"KZ", "ru"}} {
+		rule, err := NewPhoneRule(countries...)
+		require.NoError(t, err)
+		assert.Equal(t, ru.re.String(), rule.re.String(), "%v", countries)
+	}
+}
+
+func TestCardRule(t *testing.T) {
+	masker := NewMasker()
+	masker.AddRule(NewCardRule(), LastFourStrategy{})
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"карта с пробелами", "карта 4111 1111 1111 1111 оплачена", "карта **** **** **** 1111 оплачена"},
+		{"карта слитно", "4276380012345679", "************5679"},
+		{"карта через дефис", "5555-5555-5555-4444", "****-****-****-4444"},
+		{"номер заказа не проходит Луна", "заказ 4111111111111112", "заказ 4111111111111112"},
+		{"короткое число", "1234 5678", "1234 5678"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, masker.Mask(test.input))
+		})
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	t.Run("стратегии по правилам", func(t *testing.T) {
+		masker, err := ParseRules("link, phone:last4, card", "stars", nil)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"link", "phone", "card"}, masker.RuleNames())
+
+		result := masker.Mask("http://a.ru +79123456789 4111111111111111")
+		assert.Equal(t, "http://**** +*******6789 ****************", result)
+	})
+
+	t.Run("ошибки в описании", func(t *testing.T) {
+		_, err := ParseRules("email", "stars", nil)
+		assert.Error(t, err)
+
+		_, err = ParseRules("link,link", "stars", nil)
+		assert.Error(t, err)
+
+		_, err = ParseRules("link:unknown", "stars", nil)
+		assert.Error(t, err)
+
+		_, err = ParseRules(" , ", "stars", nil)
+		assert.Error(t, err)
+	})
+}
+
+// naiveCardFind - перебор всех пар групп, эталон для CardRule.Find
+func naiveCardFind(line string) []Match {
+	var matches []Match
+	for _, loc := range digitRunPattern.FindAllStringIndex(line, -1) {
+		groups := digitGroups(line, loc[0], loc[1])
+		for first := 0; first < len(groups); first++ {
+			for last := len(groups) - 1; last >= first; last-- {
+				start, end := groups[first][0], groups[last][1]
+				digits := len(strings.Map(func(r rune) rune {
+					if r >= '0' && r <= '9' {
+						return r
+					}
+					return -1
+				}, line[start:end]))
+				if digits < 13 || digits > 19 || !luhnValid(line[start:end]) {
+					continue
+				}
+				matches = append(matches, Match{Start: start, End: end, Rule: "card"})
+				first = last
+				break
+			}
+		}
+	}
+	return matches
+}
+
+func TestCardRule_MatchesNaive(t *testing.T) {
+	r := rand.New(rand.NewPCG(3, 4))
+	rule := NewCardRule()
+	for range 2000 {
+		var b strings.Builder
+		for range r.IntN(12) {
+			for range 1 + r.IntN(6) {
+				b.WriteByte(byte('0' + r.IntN(10)))
+			}
+			b.WriteByte(" - x"[r.IntN(4)])
+		}
+		line := b.String()
+		assert.Equal(t, naiveCardFind(line), rule.Find(line), "%q", line)
+	}
+}
+
+func TestCardRule_LongDigitRun(t *testing.T) {
+	line := strings.Repeat("12 ", 3000) + "4111 1111 1111 1111"
+
+	start := time.Now()
+	matches := NewCardRule().Find(line)
+	assert.Less(t, time.Since(start), time.Second, "поиск не должен зависеть от длины строки кубически")
+	assert.NotEmpty(t, matches)
+}
+
+func TestLuhnValid(t *testing.T) {
+	assert.True(t, luhnValid("4111 1111 1111 1111"))
+	assert.True(t, luhnValid("79927398713"))
+	assert.False(t, luhnValid("79927398710"))
+	assert.False(t, luhnValid(""))
+}
+
+// legacyLinkFind - прежний поиск ссылок (strings.EqualFold на каждой позиции),
+// эталон для сравнения результатов и бенчмарков "до/после"
+func legacyLinkFind(line string) []Match {
+	var matches []Match
+	for i := 0; i < len(line); i++ {
+		for _, scheme := range []string{"http://", "https://"} {
+			if i+len(scheme) > len(line) || !strings.EqualFold(line[i:i+len(scheme)], scheme) {
+				continue
+			}
+
+			end := i + len(scheme)
+			for end < len(line) {
+				ch, size := utf8.DecodeRuneInString(line[end:])
+				if unicode.IsSpace(ch) {
+					break
+				}
+				end += size
+			}
+
+			matches = append(matches, Match{Start: i, End: end, Keep: len(scheme), Rule: "link"})
+			i = end - 1
+			break
+		}
+	}
+	return matches
+}
+
+func TestLinkRule_SameAsLegacy(t *testing.T) {
+	rule := NewLinkRule()
+	inputs := []string{
+		"",
+		"h",
+		"http:/",
+		"http://",
+		"HtTpS://Пример.рф/путь?q=1 и дальше",
+		"xhttp://a.io\thttps://b.io c http://d.io e",
+		"http://a.io\u0085next",
+		"hhttp://a.io,http://b.io",
+		"https:// http://\r\n",
+		"битый \xff\xfe http://a.io/\xff end",
+		strings.Repeat("текст с http://link.com ", 50),
+	}
+	for _, input := range inputs {
+		assert.Equal(t, legacyLinkFind(input), rule.Find(input), "%q", input)
+	}
+
+	r := rand.New(rand.NewPCG(1, 2))
+	alphabet := []string{"h", "H", "t", "T", "p", "s", "S", ":", "/", " ", "\t", " ", "я", "\xff", "a.io"}
+	for range 2000 {
+		var b strings.Builder
+		for range r.IntN(40) {
+			b.WriteString(alphabet[r.IntN(len(alphabet))])
+		}
+		input := b.String()
+		require.Equal(t, legacyLinkFind(input), rule.Find(input), "%q", input)
+	}
+}
+
+func BenchmarkLinkRule(b *testing.B) {
+	inputs := []struct {
+		name string
+		line string
+	}{
+		{"NoLinks", "просто текст без ссылок, но с буквой h и словом https без двоеточия"},
+		{"Short", "http://example.com"},
+		{"LongText", strings.Repeat("текст с http://link.com ", 100)},
+	}
+	rule := NewLinkRule()
+
+	for _, input := range inputs {
+		b.Run("before/"+input.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				legacyLinkFind(input.line)
+			}
+		})
+		b.Run("after/"+input.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				rule.Find(input.line)
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package masking
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// StarsStrategy - каждый символ после открытой части заменяется на '*'
+// (исходное поведение maskLink)
+type StarsStrategy struct{}
+
+func (s StarsStrategy) Replace(value string, m Match) string {
+	var b strings.Builder
+	s.writeReplace(&b, value, m)
+	return b.String()
+}
+
+func (StarsStrategy) writeReplace(b *strings.Builder, value string, m Match) {
+	keep := min(m.Keep, len(value))
+	b.WriteString(value[:keep])
+	writeStars(b, value[keep:])
+}
+
+// LastFourStrategy - оставляет последние четыре цифры и разделители,
+// остальное заменяется на '*': "+7 912 345-67-89" -> "+* *** ***-67-89"
+type LastFourStrategy struct{}
+
+func (LastFourStrategy) Replace(value string, m Match) string {
+	keep := min(m.Keep, len(value))
+
+	// Открытыми остаются цифры начиная с четвертой с конца
+	visibleFrom := len(value)
+	for i, visible := len(value)-1, 4; i >= keep && visible > 0; i-- {
+		if value[i] >= '0' && value[i] <= '9' {
+			visibleFrom = i
+			visible--
+		}
+	}
+
+	var b strings.Builder
+	b.Grow(len(value))
+	b.WriteString(value[:keep])
+	for i := keep; i < len(value); {
+		c := value[i]
+		switch {
+		case c >= utf8.RuneSelf:
+			// Многобайтный символ (или битый байт) заменяется одной '*'
+			_, size := utf8.DecodeRuneInString(value[i:])
+			b.WriteByte('*')
+			i += size
+			continue
+		case c >= '0' && c <= '9' && i >= visibleFrom,
+			strings.IndexByte(" -().+", c) >= 0:
+			b.WriteByte(c)
+		default:
+			b.WriteByte('*')
+		}
+		i++
+	}
+	return b.String()
+}
+
+// HostForm - в каком виде DomainStrategy выводит домен
+type HostForm int
+
+const (
+	// HostAsIs - как в исходной строке
+	HostAsIs HostForm = iota
+	// HostUnicode - в Unicode и нижнем регистре: пример.рф
+	HostUnicode
+	// HostASCII - в punycode и нижнем регистре: xn--e1afmkfd.xn--p1ai
+	HostASCII
+)
+
+// DomainStrategy - схема и домен ссылки остаются открытыми, остальное (логин,
+// порт, путь, параметры) заменяется на '*': "https://u@пример.рф/путь" ->
+// "https://**пример.рф/*****". Совпадение без домена (телефон, карта)
+// маскируется как у StarsStrategy.
+type DomainStrategy struct {
+	Form HostForm
+}
+
+func (s DomainStrategy) Replace(value string, m Match) string {
+	keep := min(m.Keep, len(value))
+	start, end := linkHostBounds(value, keep)
+	hostEnd := start + len(strings.TrimRight(value[start:end], "."))
+	host := value[start:hostEnd]
+	if host == "" {
+		return StarsStrategy{}.Replace(value, m)
+	}
+
+	switch s.Form {
+	case HostUnicode:
+		if converted, err := HostToUnicode(host); err == nil {
+			host = converted
+		}
+	case HostASCII:
+		if converted, err := HostToASCII(host); err == nil {
+			host = converted
+		}
+	}
+
+	var b strings.Builder
+	b.Grow(len(value))
+	b.WriteString(value[:keep])
+	writeStars(&b, value[keep:start])
+	b.WriteString(host)
+	// Точка после домена - скорее конец предложения, она остается на месте
+	b.WriteString(value[hostEnd:end])
+	writeStars(&b, value[end:])
+	return b.String()
+}
+
+// writeStars пишет по одной '*' на каждый символ s
+func writeStars(b *strings.Builder, s string) {
+	for range utf8.RuneCountInString(s) {
+		b.WriteByte('*')
+	}
+}
+
+// Звездочки по числу символов выдают длину значения, а вместе с открытой
+// схемой этого бывает достаточно, чтобы угадать известную ссылку.
+// Следующие стратегии длину не раскрывают.
+
+// RedactStrategy - открытая часть (схема ссылки) и постоянная заглушка:
+// "https://example.com/a" -> "https://[REDACTED]"
+type RedactStrategy struct {
+	// Placeholder - текст заглушки, по умолчанию "[REDACTED]"
+	Placeholder string
+}
+
+func (s RedactStrategy) Replace(value string, m Match) string {
+	placeholder := s.Placeholder
+	if placeholder == "" {
+		placeholder = "[REDACTED]"
+	}
+	return value[:min(m.Keep, len(value))] + placeholder
+}
+
+// LabelStrategy - значение целиком заменяется меткой правила и его номером
+// в наборе правил (с 1): при --rules phone,card,link ссылка станет "[URL#3]"
+type LabelStrategy struct{}
+
+// ruleLabels - метки встроенных правил, для остальных - имя в верхнем регистре
+var ruleLabels = map[string]string{"link": "URL", "phone": "PHONE", "card": "CARD"}
+
+func (LabelStrategy) Replace(_ string, m Match) string {
+	label, ok := ruleLabels[m.Rule]
+	if !ok {
+		label = strings.ToUpper(m.Rule)
+	}
+	return "[" + label + "#" + strconv.Itoa(m.entry+1) + "]"
+}
+
+// PadStrategy - открытая часть и случайное число '*' от Min до Max,
+// не связанное с длиной значения. Уже замаскированное значение (только
+// '*' подходящей длины) не меняется, чтобы повторная маскировка была
+// идемпотентной, как у StarsStrategy.
+type PadStrategy struct {
+	Min int
+	Max int
+}
+
+const (
+	defaultPadMin = 8
+	defaultPadMax = 32
+)
+
+func (s PadStrategy) Replace(value string, m Match) string {
+	lo, hi := s.Min, s.Max
+	if lo <= 0 && hi <= 0 {
+		lo, hi = defaultPadMin, defaultPadMax
+	}
+	hi = max(lo, hi)
+
+	keep := min(m.Keep, len(value))
+	rest := value[keep:]
+	if len(rest) >= lo && len(rest) <= hi && strings.Trim(rest, "*") == "" {
+		return value
+	}
+	return value[:keep] + strings.Repeat("*", lo+rand.IntN(hi-lo+1))
+}
+
+// StrategyByName возвращает стратегию по имени из CLI
+func StrategyByName(name string) (Strategy, error) {
+	switch strings.ToLower(name) {
+	case "", "stars":
+		return StarsStrategy{}, nil
+	case "last4":
+		return LastFourStrategy{}, nil
+	case "domain":
+		return DomainStrategy{Form: HostAsIs}, nil
+	case "domain-unicode":
+		return DomainStrategy{Form: HostUnicode}, nil
+	case "domain-ascii":
+		return DomainStrategy{Form: HostASCII}, nil
+	case "redact":
+		return RedactStrategy{}, nil
+	case "label":
+		return LabelStrategy{}, nil
+	case "pad":
+		return PadStrategy{}, nil
+	case "numbered":
+		return NewPlaceholderStrategy(), nil
+	default:
+		return nil, fmt.Errorf("неизвестная стратегия маскировки: %q", name)
+	}
+}
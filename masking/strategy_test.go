@@ -0,0 +1,48 @@
+package masking
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLengthHidingStrategies(t *testing.T) {
+	masker, err := ParseRules("phone:redact,card,link:label", "stars", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "ссылка [URL#3] и тел. [REDACTED], карта ****************",
+		masker.Mask("ссылка https://example.com/a и тел. +79123456789, карта 4111111111111111"))
+
+	masker, err = ParseRules("link", "redact", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "https://[REDACTED] и http://[REDACTED]", masker.Mask("https://a.io и http://very-long.example.com/path"))
+	assert.Equal(t, "https://[REDACTED]", masker.Mask("https://[REDACTED]"), "повторная маскировка ничего не меняет")
+
+	custom := NewMasker()
+	custom.AddRule(NewLinkRule(), RedactStrategy{Placeholder: "<скрыто>"})
+	assert.Equal(t, "http://<скрыто>", custom.Mask("http://a.io"))
+}
+
+func TestPadStrategy(t *testing.T) {
+	masker := NewMasker()
+	masker.AddRule(NewLinkRule(), PadStrategy{Min: 4, Max: 6})
+
+	lengths := make(map[int]bool)
+	for range 200 {
+		masked := masker.Mask("http://a.io")
+		require.True(t, strings.HasPrefix(masked, "http://"))
+		stars := strings.TrimPrefix(masked, "http://")
+		require.Equal(t, "", strings.Trim(stars, "*"))
+		require.GreaterOrEqual(t, len(stars), 4)
+		require.LessOrEqual(t, len(stars), 6)
+		lengths[len(stars)] = true
+
+		assert.Equal(t, masked, masker.Mask(masked), "замаскированное значение не меняется")
+	}
+	assert.Len(t, lengths, 3, "длина не зависит от значения и случайна")
+
+	masked := PadStrategy{}.Replace("https://a.io", Match{Keep: 8})
+	assert.GreaterOrEqual(t, len(masked), 8+defaultPadMin)
+	assert.LessOrEqual(t, len(masked), 8+defaultPadMax)
+}
@@ -0,0 +1,231 @@
+package masking
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"unicode/utf8"
+)
+
+const (
+	defaultMaxLineSize = 64 << 10
+	streamReadSize     = 32 << 10
+	// streamOverlap - сколько байт хвоста не отдается при разрезании длинной
+	// строки: совпадение короче этого (телефон, карта), пересекающее место
+	// разреза, целиком попадает в буфер и будет найдено
+	streamOverlap = 64
+)
+
+// StreamOption - настройка NewMaskingReader и NewMaskingWriter
+type StreamOption func(*streamMasker)
+
+// WithMasker задает движок маскировки. По умолчанию маскируются только ссылки.
+func WithMasker(masker *Masker) StreamOption {
+	return func(s *streamMasker) {
+		if masker != nil {
+			s.masker = masker
+		}
+	}
+}
+
+// WithMaxLineSize - после скольких байт без перевода строки поток
+// разрезается, чтобы не копить строку в памяти целиком. Режется по пробелу,
+// а если пробелов нет - по любому месту вне совпадения. Целиком копится
+// только одно совпадение длиннее лимита (например, очень длинная ссылка).
+func WithMaxLineSize(size int) StreamOption {
+	return func(s *streamMasker) {
+		if size > 0 {
+			s.maxLine = size
+		}
+	}
+}
+
+// streamMasker копит неполную строку между вызовами Read/Write и отдает
+// наружу только те части, которые уже нельзя изменить дописанными данными
+type streamMasker struct {
+	masker  *Masker
+	maxLine int
+	pending []byte
+	// retryAt - с какой длины pending снова пробовать разрезать строку, которую
+	// разрезать не удалось: без этого одна длинная ссылка просматривалась бы
+	// заново после каждого чтения
+	retryAt int
+}
+
+func newStreamMasker(opts []StreamOption) *streamMasker {
+	s := &streamMasker{masker: linkMasker, maxLine: defaultMaxLineSize}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// mask дописывает в dst замаскированные полные строки из pending.
+// final - данных больше не будет, остаток маскируется как есть.
+func (s *streamMasker) mask(dst []byte, final bool) []byte {
+	data := s.pending
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		dst = append(dst, s.masker.Mask(string(data[:i]))...)
+		dst = append(dst, '\n')
+		data = data[i+1:]
+		s.retryAt = 0
+	}
+
+	switch {
+	case final:
+		dst = append(dst, s.masker.Mask(string(data))...)
+		data = data[:0]
+	case len(data) > s.maxLine && len(data) >= s.retryAt:
+		dst, data = s.cut(dst, data)
+	}
+
+	s.pending = append(s.pending[:0], data...)
+	return dst
+}
+
+// cut отдает замаскированное начало длинной строки data и возвращает остаток.
+// Совпадения ищутся по всей data, поэтому разрез не меняет маскировку начала.
+func (s *streamMasker) cut(dst, data []byte) ([]byte, []byte) {
+	line := string(data)
+	matches := s.masker.overlapping(line)
+	cut := safeCut(line, matches)
+	if cut == 0 {
+		s.retryAt = 2 * len(data)
+		return dst, data
+	}
+	s.retryAt = 0
+
+	before := 0
+	for before < len(matches) && matches[before].End <= cut {
+		before++
+	}
+	matches, _ = s.masker.dropAllowed(line, matches[:before])
+	return append(dst, s.masker.Replace(line[:cut], matches)...), data[cut:]
+}
+
+// safeCut ищет место, по которому длинную строку можно разрезать, не разорвав
+// совпадение (в том числе разрешенную ссылку); 0 - такого места нет и строка
+// копится дальше. Предпочитается пробел: по нему правила с границами слов
+// найдут в остатке то же, что и в целой строке.
+func safeCut(line string, matches []Match) int {
+	fallback := 0
+	m := len(matches) - 1
+	for i := len(line) - streamOverlap - 1; i > 0; i-- {
+		for m >= 0 && matches[m].Start > i {
+			m--
+		}
+		if m >= 0 && i < matches[m].End {
+			i = matches[m].Start
+			if fallback == 0 && i > 0 {
+				fallback = i
+			}
+			continue
+		}
+		switch line[i] {
+		case ' ', '\t', '\r', '\v', '\f':
+			return i + 1
+		}
+		if fallback == 0 && utf8.RuneStart(line[i]) {
+			fallback = i
+		}
+	}
+	return fallback
+}
+
+// maskingReader - см. NewMaskingReader
+type maskingReader struct {
+	r   io.Reader
+	s   *streamMasker
+	buf []byte
+	out []byte
+	err error
+}
+
+// NewMaskingReader возвращает Reader, который читает r и отдает текст
+// с замаскированными фрагментами. Ссылка, разорванная между вызовами
+// Read исходного r, маскируется целиком.
+func NewMaskingReader(r io.Reader, opts ...StreamOption) io.Reader {
+	return &maskingReader{r: r, s: newStreamMasker(opts)}
+}
+
+func (mr *maskingReader) Read(p []byte) (int, error) {
+	for len(mr.out) == 0 {
+		if mr.err != nil {
+			return 0, mr.err
+		}
+		if mr.buf == nil {
+			mr.buf = make([]byte, streamReadSize)
+		}
+
+		n, err := mr.r.Read(mr.buf)
+		mr.s.pending = append(mr.s.pending, mr.buf[:n]...)
+		if err != nil {
+			mr.err = err
+		}
+		// Остаток отдается и при ошибке чтения: он уже получен от r
+		mr.out = mr.s.mask(mr.out[:0], err != nil)
+	}
+
+	n := copy(p, mr.out)
+	mr.out = mr.out[n:]
+	return n, nil
+}
+
+// MaskingWriter - см. NewMaskingWriter
+type MaskingWriter struct {
+	w   io.Writer
+	s   *streamMasker
+	out []byte
+	err error
+}
+
+var errWriterClosed = errors.New("запись в закрытый MaskingWriter")
+
+// NewMaskingWriter возвращает Writer, который маскирует текст и пишет его в w.
+// Незавершенная строка придерживается до следующего Write, поэтому после
+// записи нужно вызвать Close - он допишет остаток. Сам w не закрывается.
+func NewMaskingWriter(w io.Writer, opts ...StreamOption) *MaskingWriter {
+	return &MaskingWriter{w: w, s: newStreamMasker(opts)}
+}
+
+func (mw *MaskingWriter) Write(p []byte) (int, error) {
+	if mw.err != nil {
+		return 0, mw.err
+	}
+	mw.s.pending = append(mw.s.pending, p...)
+	if err := mw.flush(false); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close маскирует и дописывает незавершенную строку
+func (mw *MaskingWriter) Close() error {
+	if mw.err != nil {
+		if mw.err == errWriterClosed {
+			return nil
+		}
+		return mw.err
+	}
+	if err := mw.flush(true); err != nil {
+		return err
+	}
+	mw.err = errWriterClosed
+	return nil
+}
+
+func (mw *MaskingWriter) flush(final bool) error {
+	mw.out = mw.s.mask(mw.out[:0], final)
+	if len(mw.out) == 0 {
+		return nil
+	}
+	if _, err := mw.w.Write(mw.out); err != nil {
+		mw.err = err
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package masking
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const streamInput = "first http://example.com/a?b=c\r\nsecond https://ПРИМЕР.рф/путь end\n\nhttp://tail.io"
+const streamExpected = "first http://*****************\r\nsecond https://************** end\n\nhttp://*******"
+
+func TestMaskingReader(t *testing.T) {
+	t.Run("по одному байту", func(t *testing.T) {
+		data, err := io.ReadAll(NewMaskingReader(iotest.OneByteReader(strings.NewReader(streamInput))))
+		require.NoError(t, err)
+		assert.Equal(t, streamExpected, string(data))
+	})
+
+	t.Run("маленький буфер получателя", func(t *testing.T) {
+		var out bytes.Buffer
+		buf := make([]byte, 3)
+		r := NewMaskingReader(strings.NewReader(streamInput))
+		for {
+			n, err := r.Read(buf)
+			out.Write(buf[:n])
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+		}
+		assert.Equal(t, streamExpected, out.String())
+	})
+
+	t.Run("ошибка источника", func(t *testing.T) {
+		r := NewMaskingReader(io.MultiReader(strings.NewReader("see http://a.io"), iotest.ErrReader(io.ErrUnexpectedEOF)))
+		data, err := io.ReadAll(r)
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+		assert.Equal(t, "see http://****", string(data))
+	})
+}
+
+func TestMaskingWriter(t *testing.T) {
+	masker, err := ParseRules("link,phone", "stars", []string{"ru"})
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	w := NewMaskingWriter(&out, WithMasker(masker))
+	input := streamInput + " +7 912 345-67-89"
+	for i := range len(input) {
+		_, err := w.Write([]byte{input[i]})
+		require.NoError(t, err)
+	}
+	assert.NotContains(t, out.String(), "tail", "незавершенная строка придерживается до Close")
+
+	require.NoError(t, w.Close())
+	assert.Equal(t, streamExpected+" ****************", out.String())
+	require.NoError(t, w.Close())
+
+	_, err = w.Write([]byte("x"))
+	assert.Error(t, err)
+}
+
+func TestMaskingWriter_LongLine(t *testing.T) {
+	masker, err := ParseRules("link,phone", "stars", []string{"ru"})
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	w := NewMaskingWriter(&out, WithMasker(masker), WithMaxLineSize(100))
+	word := "слово http://a.io/x +7 912 345-67-89 "
+	for range 50 {
+		_, err := io.WriteString(w, word)
+		require.NoError(t, err)
+	}
+	assert.Less(t, len(w.s.pending), 200, "длинная строка без переводов не копится целиком")
+	require.NoError(t, w.Close())
+
+	assert.Equal(t, strings.Repeat("слово http://****** **************** ", 50), out.String())
+}
+
+func TestMaskingWriter_LongLink(t *testing.T) {
+	var out bytes.Buffer
+	w := NewMaskingWriter(&out, WithMaxLineSize(16))
+	link := "http://a.io/" + strings.Repeat("x", 100)
+	for i := 0; i < len(link); i += 7 {
+		_, err := io.WriteString(w, link[i:min(i+7, len(link))])
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	assert.Equal(t, "http://"+strings.Repeat("*", len(link)-len("http://")), out.String(),
+		"ссылка длиннее лимита не разрезается")
+}
+
+func TestMaskingReader_LineWithoutSpaces(t *testing.T) {
+	masker, err := ParseRules("link,phone", "stars", []string{"ru"})
+	require.NoError(t, err)
+
+	t.Run("режется вне совпадений", func(t *testing.T) {
+		// Ссылка тянется до пробела, поэтому в строке без пробелов ее нет
+		chunk := strings.Repeat("я", 500) + ",+79123456789," + strings.Repeat("x", 500)
+		input := strings.Repeat(chunk, 8<<20/len(chunk))
+		expected := strings.Repeat(strings.Repeat("я", 500)+",************,"+strings.Repeat("x", 500), 8<<20/len(chunk))
+
+		start := time.Now()
+		r := NewMaskingReader(strings.NewReader(input), WithMasker(masker))
+		var out bytes.Buffer
+		buf := make([]byte, streamReadSize)
+		maxPending := 0
+		for {
+			n, err := r.Read(buf)
+			out.Write(buf[:n])
+			maxPending = max(maxPending, len(r.(*maskingReader).s.pending))
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+		}
+		assert.Less(t, time.Since(start), 2*time.Second)
+		assert.LessOrEqual(t, maxPending, defaultMaxLineSize+streamReadSize)
+		assert.Equal(t, expected, out.String())
+	})
+
+	t.Run("одна длинная ссылка", func(t *testing.T) {
+		link := "http://a.io/" + strings.Repeat("x", 8<<20)
+		start := time.Now()
+		data, err := io.ReadAll(NewMaskingReader(strings.NewReader(link)))
+		require.NoError(t, err)
+		assert.Less(t, time.Since(start), 2*time.Second, "ссылка не просматривается заново после каждого чтения")
+		assert.Equal(t, "http://"+strings.Repeat("*", len(link)-len("http://")), string(data))
+	})
+}
@@ -0,0 +1,313 @@
+package service
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Что делать с бинарными файлами внутри архива
+const (
+	BinaryCopy = "copy"
+	BinaryDrop = "drop"
+)
+
+// Результат обработки файла внутри архива (EntryReport.Status)
+const (
+	EntryMasked    = "masked"
+	EntryUnchanged = "unchanged"
+	EntryCopied    = "copied"
+	EntryDropped   = "dropped"
+)
+
+// binarySniffSize - сколько байт в начале файла проверять на нулевой байт
+const binarySniffSize = 8000
+
+// archiveEntry - файл внутри архива вместе с исходным заголовком
+type archiveEntry struct {
+	name      string
+	tarHeader *tar.Header
+	zipFile   *zip.File
+	data      []byte
+	format    Format
+	doc       *document // nil - файл копируется или удаляется без маскировки
+	dropped   bool
+	first     int // номер первого сегмента документа среди всех сегментов архива
+}
+
+// ArchiveProducer разбирает tar (tar.gz, tgz) или zip: каждый текстовый файл
+// превращается в документ своего формата, бинарные файлы и каталоги
+// передаются в ArchivePresenter без изменений.
+type ArchiveProducer struct {
+	filePath string
+	options  FormatOptions
+	isZip    bool
+	office   bool // документ Office: маскируются только XML-части
+	entries  []*archiveEntry
+	segments []string
+}
+
+// ArchivePresenter собирает новый архив того же типа с сохранением
+// имен, прав и времени изменения файлов
+type ArchivePresenter struct {
+	filePath  string
+	gzipLevel int
+	source    *ArchiveProducer
+	reports   []EntryReport
+}
+
+func NewArchivePair(inputPath, outputPath string, options FormatOptions) (*ArchiveProducer, *ArchivePresenter) {
+	producer := &ArchiveProducer{filePath: inputPath, options: options}
+	return producer, &ArchivePresenter{filePath: outputPath, source: producer}
+}
+
+func (producer *ArchiveProducer) Produce() ([]string, error) {
+	data, err := readSource(producer.filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	producer.entries = nil
+	producer.segments = nil
+	producer.isZip = bytes.HasPrefix(data, []byte("PK\x03\x04")) || bytes.HasPrefix(data, []byte("PK\x05\x06"))
+	if producer.isZip {
+		err = producer.readZip(data)
+	} else {
+		err = producer.readTar(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения архива %s: %w", producer.filePath, err)
+	}
+
+	for _, entry := range producer.entries {
+		if err := producer.parseEntry(entry); err != nil {
+			return nil, fmt.Errorf("ошибка разбора %s в архиве %s: %w", entry.name, producer.filePath, err)
+		}
+	}
+	return producer.segments, nil
+}
+
+func (producer *ArchiveProducer) readTar(data []byte) error {
+	reader := tar.NewReader(bytes.NewReader(data))
+	for {
+		header, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		entry := &archiveEntry{name: header.Name, tarHeader: header}
+		if header.Typeflag == tar.TypeReg {
+			if entry.data, err = io.ReadAll(reader); err != nil {
+				return err
+			}
+		}
+		producer.entries = append(producer.entries, entry)
+	}
+}
+
+func (producer *ArchiveProducer) readZip(data []byte) error {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return err
+	}
+
+	for _, file := range reader.File {
+		entry := &archiveEntry{name: file.Name, zipFile: file}
+		if file.Mode().IsRegular() {
+			rc, err := file.Open()
+			if err != nil {
+				return err
+			}
+			entry.data, err = io.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				return err
+			}
+		}
+		producer.entries = append(producer.entries, entry)
+	}
+	return nil
+}
+
+// parseEntry разбирает текстовый файл в документ. Каталоги, ссылки и вложенные
+// архивы копируются, бинарные файлы - по политике ArchiveBinary.
+func (producer *ArchiveProducer) parseEntry(entry *archiveEntry) error {
+	regular := (entry.tarHeader != nil && entry.tarHeader.Typeflag == tar.TypeReg) ||
+		(entry.zipFile != nil && entry.zipFile.Mode().IsRegular())
+	if !regular {
+		return nil
+	}
+
+	var parse documentParser
+	if producer.office {
+		// Части документа Office удалять нельзя, поэтому политика для бинарных файлов не применяется
+		if !isOfficeXMLPart(entry.name) {
+			return nil
+		}
+		entry.format, parse = formatOfficeXML, parseOfficeXML
+	} else {
+		entry.format = DetectFormat(entry.name)
+		if entry.format == FormatArchive || entry.format == FormatOffice || isBinary(entry.data) {
+			entry.format = ""
+			entry.dropped = producer.options.ArchiveBinary == BinaryDrop
+			return nil
+		}
+
+		parse = documentParserFor(entry.format, producer.options)
+		if parse == nil {
+			parse = parseText
+		}
+	}
+
+	doc, err := parse(entry.data)
+	if err != nil {
+		return err
+	}
+
+	entry.doc = doc
+	entry.first = len(producer.segments)
+	producer.segments = append(producer.segments, doc.Segments()...)
+	return nil
+}
+
+func (presenter *ArchivePresenter) Present(lines []string) error {
+	source := presenter.source
+	if len(lines) != len(source.segments) {
+		return fmt.Errorf("архив обработан не полностью: %d из %d фрагментов", len(lines), len(source.segments))
+	}
+
+	presenter.reports = presenter.reports[:0]
+	contents := make([][]byte, len(source.entries))
+	for i, entry := range source.entries {
+		report := EntryReport{Name: entry.name, Status: EntryCopied, Format: entry.format, Size: int64(len(entry.data))}
+		contents[i] = entry.data
+
+		switch {
+		case entry.dropped:
+			report.Status = EntryDropped
+		case entry.doc != nil:
+			masked := lines[entry.first : entry.first+entry.doc.segments]
+			data, err := entry.doc.Render(masked)
+			if err != nil {
+				return fmt.Errorf("%s: %w", entry.name, err)
+			}
+			contents[i] = data
+
+			report.Status = EntryUnchanged
+			report.Segments = len(masked)
+			for j, value := range masked {
+				if value != source.segments[entry.first+j] {
+					report.Masked++
+				}
+			}
+			if report.Masked > 0 {
+				report.Status = EntryMasked
+			}
+		}
+		presenter.reports = append(presenter.reports, report)
+	}
+
+	if source.isZip {
+		return presenter.writeZip(contents)
+	}
+	return presenter.writeTar(contents)
+}
+
+func (presenter *ArchivePresenter) writeTar(contents [][]byte) error {
+	var buf bytes.Buffer
+	writer := tar.NewWriter(&buf)
+	for i, entry := range presenter.source.entries {
+		if entry.dropped {
+			continue
+		}
+
+		header := *entry.tarHeader
+		if header.Typeflag == tar.TypeReg {
+			header.Size = int64(len(contents[i]))
+		}
+		if err := writer.WriteHeader(&header); err != nil {
+			return fmt.Errorf("%s: %w", entry.name, err)
+		}
+		if _, err := writer.Write(contents[i]); err != nil {
+			return fmt.Errorf("%s: %w", entry.name, err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	level := presenter.gzipLevel
+	if level == 0 && strings.EqualFold(filepath.Ext(presenter.filePath), ".tgz") {
+		level = gzip.DefaultCompression
+	}
+	return writeDest(presenter.filePath, buf.Bytes(), level)
+}
+
+func (presenter *ArchivePresenter) writeZip(contents [][]byte) error {
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+	for i, entry := range presenter.source.entries {
+		if entry.dropped {
+			continue
+		}
+
+		// Файлы без маскировки переносятся в сжатом виде как есть
+		if entry.doc == nil {
+			if err := writer.Copy(entry.zipFile); err != nil {
+				return fmt.Errorf("%s: %w", entry.name, err)
+			}
+			continue
+		}
+
+		header := entry.zipFile.FileHeader
+		w, err := writer.CreateHeader(&header)
+		if err != nil {
+			return fmt.Errorf("%s: %w", entry.name, err)
+		}
+		if _, err := w.Write(contents[i]); err != nil {
+			return fmt.Errorf("%s: %w", entry.name, err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	// zip уже сжат, уровень gzip к нему не применяется
+	return os.WriteFile(presenter.filePath, buf.Bytes(), 0644)
+}
+
+// SetGzipLevel включает сжатие tar gzip с заданным уровнем (1-9)
+func (presenter *ArchivePresenter) SetGzipLevel(level int) {
+	presenter.gzipLevel = level
+}
+
+func (presenter *ArchivePresenter) entryReports() []EntryReport {
+	return presenter.reports
+}
+
+// parseText - обычный текст внутри архива: каждая строка - сегмент, переводы
+// строк и пробелы сохраняются, чтобы файл менялся только в местах маскировки
+func parseText(data []byte) (*document, error) {
+	doc := &document{}
+	for _, line := range splitLinesKeepEnds(string(data)) {
+		body, eol := cutEOL(line)
+		doc.addSegment(body)
+		doc.addText(eol)
+	}
+	return doc, nil
+}
+
+// isBinary - в начале файла есть нулевой байт (так же определяет бинарные файлы git)
+func isBinary(data []byte) bool {
+	return bytes.IndexByte(data[:min(len(data), binarySniffSize)], 0) >= 0
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var archiveTime = time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+
+var archiveBinary = []byte{0x89, 'P', 'N', 'G', 0x00, 0x01, 'h', 't', 't', 'p', ':', '/', '/'}
+
+func writeTarGz(t *testing.T, path string) {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "logs/", Typeflag: tar.TypeDir, Mode: 0755, ModTime: archiveTime}))
+	files := []struct {
+		name string
+		data []byte
+		mode int64
+	}{
+		{"logs/app.log", []byte("  open http://a.io/x\n\nok\n"), 0600},
+		{"logs/config.json", []byte("{\n  \"url\": \"https://b.io\"\n}\n"), 0644},
+		{"logs/clean.txt", []byte("nothing here\n"), 0644},
+		{"logs/image.png", archiveBinary, 0644},
+	}
+	for _, file := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: file.name, Typeflag: tar.TypeReg, Mode: file.mode,
+			Size: int64(len(file.data)), ModTime: archiveTime}))
+		_, err := tw.Write(file.data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0644))
+}
+
+func readTarGz(t *testing.T, path string) (map[string]string, map[string]*tar.Header) {
+	t.Helper()
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	require.NoError(t, err)
+
+	contents := make(map[string]string)
+	headers := make(map[string]*tar.Header)
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		data, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		contents[header.Name] = string(data)
+		headers[header.Name] = header
+	}
+	return contents, headers
+}
+
+func TestArchive_TarGz(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "bundle.tar.gz")
+	output := filepath.Join(dir, "masked.tgz")
+	writeTarGz(t, input)
+
+	svc := NewServiceFactory(3, false).CreateMaskService(input, output)
+	require.NoError(t, svc.Run(context.Background()))
+
+	contents, headers := readTarGz(t, output)
+	assert.Equal(t, "  open http://******\n\nok\n", contents["logs/app.log"], "пробелы и пустые строки сохраняются")
+	assert.Equal(t, "{\n  \"url\": \"https://****\"\n}\n", contents["logs/config.json"])
+	assert.Equal(t, "nothing here\n", contents["logs/clean.txt"])
+	assert.Equal(t, string(archiveBinary), contents["logs/image.png"], "бинарный файл копируется без изменений")
+
+	require.Contains(t, headers, "logs/")
+	assert.Equal(t, byte(tar.TypeDir), headers["logs/"].Typeflag)
+	assert.Equal(t, int64(0600), headers["logs/app.log"].Mode)
+	assert.True(t, archiveTime.Equal(headers["logs/app.log"].ModTime))
+
+	report := svc.Report()
+	require.NotNil(t, report)
+	assert.Equal(t, 2, report.Masked)
+	assert.Equal(t, []EntryReport{
+		{Name: "logs/", Status: EntryCopied},
+		{Name: "logs/app.log", Status: EntryMasked, Format: FormatText, Size: 25, Segments: 2, Masked: 1},
+		{Name: "logs/config.json", Status: EntryMasked, Format: FormatJSON, Size: 28, Segments: 1, Masked: 1},
+		{Name: "logs/clean.txt", Status: EntryUnchanged, Format: FormatText, Size: 13, Segments: 1},
+		{Name: "logs/image.png", Status: EntryCopied, Size: int64(len(archiveBinary))},
+	}, report.Entries)
+}
+
+func TestArchive_Zip(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "bundle.zip")
+	output := filepath.Join(dir, "masked.zip")
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	header := &zip.FileHeader{Name: "notes.md", Method: zip.Deflate, Modified: archiveTime}
+	header.SetMode(0640)
+	w, err := zw.CreateHeader(header)
+	require.NoError(t, err)
+	_, err = w.Write([]byte("[docs](https://a.io/docs)\n"))
+	require.NoError(t, err)
+	w, err = zw.Create("bin/tool")
+	require.NoError(t, err)
+	_, err = w.Write(archiveBinary)
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	require.NoError(t, os.WriteFile(input, buf.Bytes(), 0644))
+
+	factory := NewServiceFactory(2, false)
+	opts := DefaultFormatOptions()
+	opts.ArchiveBinary = BinaryDrop
+	require.NoError(t, factory.SetFormat(FormatAuto, opts))
+
+	svc := factory.CreateMaskService(input, output)
+	require.NoError(t, svc.Run(context.Background()))
+
+	reader, err := zip.OpenReader(output)
+	require.NoError(t, err)
+	defer reader.Close()
+
+	require.Len(t, reader.File, 1, "бинарный файл удален")
+	file := reader.File[0]
+	assert.Equal(t, "notes.md", file.Name)
+	assert.Equal(t, os.FileMode(0640), file.Mode())
+	assert.True(t, archiveTime.Equal(file.Modified))
+
+	rc, err := file.Open()
+	require.NoError(t, err)
+	data, err := io.ReadAll(rc)
+	rc.Close()
+	require.NoError(t, err)
+	assert.Equal(t, "[docs](https://*********)\n", string(data))
+
+	assert.Equal(t, EntryDropped, svc.Report().Entries[1].Status)
+}
+
+func TestArchive_Options(t *testing.T) {
+	assert.Equal(t, FormatArchive, DetectFormat("bundle.tar.gz"))
+	assert.Equal(t, FormatArchive, DetectFormat("bundle.tgz"))
+	assert.Equal(t, FormatArchive, DetectFormat("bundle.zip"))
+
+	opts := DefaultFormatOptions()
+	opts.ArchiveBinary = "keep"
+	assert.Error(t, opts.Validate())
+}
@@ -1,6 +1,12 @@
 package service
 
 import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
 )
@@ -33,3 +39,62 @@ func BenchmarkMaskLinks_Parallel(b *testing.B) {
 		}
 	})
 }
+
+// benchInput - файл из коротких строк лога, на которых у Service.Run
+// доминируют накладные расходы каналов
+func benchInput(b *testing.B) (string, int64) {
+	b.Helper()
+	path := filepath.Join(b.TempDir(), "in.txt")
+	var sb strings.Builder
+	for i := 0; sb.Len() < 8<<20; i++ {
+		if i%4 == 0 {
+			fmt.Fprintf(&sb, "GET http://host%d.io/api?id=%d 200\n", i%100, i)
+		} else {
+			fmt.Fprintf(&sb, "INFO request %d done\n", i)
+		}
+	}
+	if err := os.WriteFile(path, []byte(sb.String()), 0644); err != nil {
+		b.Fatal(err)
+	}
+	return path, int64(sb.Len())
+}
+
+func BenchmarkServiceRun_File(b *testing.B) {
+	input, size := benchInput(b)
+	output := filepath.Join(b.TempDir(), "out.txt")
+	b.SetBytes(size)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		svc := NewService(NewFileProducer(input), NewFilePresenter(output))
+		svc.SetWorkers(runtime.NumCPU())
+		if err := svc.Run(context.Background()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMaskChunked_File(b *testing.B) {
+	input, size := benchInput(b)
+	output := filepath.Join(b.TempDir(), "out.txt")
+	b.SetBytes(size)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		src, err := os.Open(input)
+		if err != nil {
+			b.Fatal(err)
+		}
+		dst, err := os.Create(output)
+		if err != nil {
+			b.Fatal(err)
+		}
+		w := bufio.NewWriter(dst)
+		if _, err := MaskChunked(context.Background(), src, size, w, ChunkConfig{Workers: runtime.NumCPU()}); err != nil {
+			b.Fatal(err)
+		}
+		w.Flush()
+		dst.Close()
+		src.Close()
+	}
+}
@@ -0,0 +1,289 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"LinkMaskirator/masking"
+)
+
+// ChunkConfig - настройки параллельной обработки файла кусками
+type ChunkConfig struct {
+	Workers int
+	// ChunkSize - примерный размер куска в байтах, кусок продлевается до конца строки
+	ChunkSize int
+	// SlowMode - пауза 100 мс на строку, как у воркеров Service
+	SlowMode bool
+	Masker   *masking.Masker
+}
+
+const defaultChunkSize = 1 << 20
+
+// chunk - диапазон байт [start, end) входного файла, выровненный по переводам строк
+type chunk struct {
+	index int
+	start int64
+	end   int64
+}
+
+type chunkResult struct {
+	chunk
+	out *bytes.Buffer
+	chunkStats
+	err error
+}
+
+// chunkCommit получает результат куска в исходном порядке. out - строки
+// без пробелов по краям и пустых строк, соединенные "\n", как у FilePresenter.
+type chunkCommit func(out []byte, end int64, stats chunkStats) error
+
+// chunkStats - счетчики отчета по одному куску
+type chunkStats struct {
+	segments int
+	masked   int
+	allowed  int
+}
+
+var (
+	chunkReadPool = sync.Pool{New: func() any { return new([]byte) }}
+	chunkOutPool  = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+)
+
+// MaskChunked маскирует байты [0, size) из src и пишет результат в dst.
+// В отличие от Service.Run, воркеры получают не строки по одной, а куски
+// по ChunkSize байт, поэтому накладные расходы каналов не зависят от длины
+// строк, а файл не читается в память целиком. Результат совпадает с
+// FileProducer + FilePresenter.
+func MaskChunked(ctx context.Context, src io.ReaderAt, size int64, dst io.Writer, cfg ChunkConfig) (*Report, error) {
+	report := &Report{}
+	written := false
+	err := maskChunks(ctx, src, 0, size, cfg, func(out []byte, _ int64, stats chunkStats) error {
+		report.Segments += stats.segments
+		report.Masked += stats.masked
+		report.Allowed += stats.allowed
+		if len(out) == 0 {
+			return nil
+		}
+		if written {
+			if _, err := io.WriteString(dst, "\n"); err != nil {
+				return err
+			}
+		}
+		written = true
+		_, err := dst.Write(out)
+		return err
+	})
+	return report, err
+}
+
+// maskChunks делит [start, size) на куски, маскирует их параллельно и отдает
+// в commit строго по порядку. При отмене ctx зафиксированными остаются только
+// куски, обработанные целиком и без пропусков перед ними.
+func maskChunks(ctx context.Context, src io.ReaderAt, start, size int64, cfg ChunkConfig, commit chunkCommit) error {
+	if cfg.Workers < 1 {
+		cfg.Workers = 1
+	}
+	if cfg.ChunkSize <= 0 {
+		cfg.ChunkSize = defaultChunkSize
+	}
+	if cfg.Masker == nil {
+		cfg.Masker = defaultMasker
+	}
+	// Нумерованные заглушки выдаются по порядку строк: куски маскирует
+	// один воркер, строго друг за другом
+	if cfg.Masker.Ordered() {
+		cfg.Workers = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Кусков в работе не больше 2*Workers: иначе быстрые воркеры
+	// накопили бы в памяти результаты, ждущие медленный кусок
+	tokens := make(chan struct{}, 2*cfg.Workers)
+	jobs := make(chan chunk)
+	results := make(chan chunkResult)
+
+	var splitErr error
+	splitDone := make(chan struct{})
+	go func() {
+		defer close(splitDone)
+		defer close(jobs)
+		for index := 0; start < size; index++ {
+			select {
+			case tokens <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			end, err := chunkEnd(src, start, size, int64(cfg.ChunkSize))
+			if err != nil {
+				splitErr = err
+				cancel()
+				return
+			}
+			select {
+			case jobs <- chunk{index: index, start: start, end: end}:
+			case <-ctx.Done():
+				return
+			}
+			start = end
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for range cfg.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				result := maskChunk(ctx, src, job, cfg)
+				select {
+				case results <- result:
+				case <-ctx.Done():
+					chunkOutPool.Put(result.out)
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	pending := make(map[int]chunkResult)
+	next := 0
+	var firstErr error
+	for result := range results {
+		if firstErr != nil {
+			chunkOutPool.Put(result.out)
+			continue
+		}
+		if result.err != nil {
+			firstErr = result.err
+			cancel()
+			chunkOutPool.Put(result.out)
+			continue
+		}
+
+		pending[result.index] = result
+		for {
+			ready, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+
+			err := commit(ready.out.Bytes(), ready.end, ready.chunkStats)
+			chunkOutPool.Put(ready.out)
+			<-tokens
+			if err != nil {
+				firstErr = err
+				cancel()
+				break
+			}
+		}
+	}
+	for _, result := range pending {
+		chunkOutPool.Put(result.out)
+	}
+
+	<-splitDone
+	if splitErr != nil {
+		return splitErr
+	}
+	if firstErr != nil {
+		return firstErr
+	}
+	// Воркеры могли выйти по отмене, не вернув ошибку
+	return ctx.Err()
+}
+
+// chunkEnd находит конец куска: первый перевод строки не раньше start+chunkSize
+func chunkEnd(src io.ReaderAt, start, size, chunkSize int64) (int64, error) {
+	end := start + chunkSize
+	if end >= size {
+		return size, nil
+	}
+
+	var window [4096]byte
+	for pos := end - 1; pos < size; {
+		n, err := src.ReadAt(window[:min(int64(len(window)), size-pos)], pos)
+		if i := bytes.IndexByte(window[:n], '\n'); i >= 0 {
+			return pos + int64(i) + 1, nil
+		}
+		pos += int64(n)
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+		if n == 0 {
+			break
+		}
+	}
+	return size, nil
+}
+
+// maskChunk читает кусок в буфер из пула и маскирует его построчно
+func maskChunk(ctx context.Context, src io.ReaderAt, job chunk, cfg ChunkConfig) chunkResult {
+	result := chunkResult{chunk: job, out: chunkOutPool.Get().(*bytes.Buffer)}
+	result.out.Reset()
+
+	bufPtr := chunkReadPool.Get().(*[]byte)
+	buf := *bufPtr
+	if cap(buf) < int(job.end-job.start) {
+		buf = make([]byte, job.end-job.start)
+	}
+	buf = buf[:job.end-job.start]
+	n, err := src.ReadAt(buf, job.start)
+	// Строки копируются один раз на кусок, буфер чтения сразу возвращается в пул
+	text := string(buf[:n])
+	*bufPtr = buf
+	chunkReadPool.Put(bufPtr)
+	if err != nil && !(err == io.EOF && n == len(buf)) {
+		result.err = err
+		return result
+	}
+
+	if ctx.Err() != nil {
+		result.err = ctx.Err()
+		return result
+	}
+
+	result.out.Grow(len(text))
+	for len(text) > 0 {
+		line, rest, _ := strings.Cut(text, "\n")
+		text = rest
+		line = strings.TrimSuffix(line, "\r")
+
+		if cfg.SlowMode {
+			select {
+			case <-time.After(100 * time.Millisecond):
+			case <-ctx.Done():
+				result.err = ctx.Err()
+				return result
+			}
+		}
+
+		masked, allowed := cfg.Masker.MaskAllowed(line)
+		result.segments++
+		result.allowed += allowed
+		if masked != line {
+			result.masked++
+		}
+
+		masked = strings.TrimSpace(masked)
+		if masked == "" {
+			continue
+		}
+		if result.out.Len() > 0 {
+			result.out.WriteByte('\n')
+		}
+		result.out.WriteString(masked)
+	}
+	return result
+}
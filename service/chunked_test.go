@@ -0,0 +1,185 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"LinkMaskirator/masking"
+)
+
+func chunkedInput(lines int) string {
+	var b strings.Builder
+	for i := range lines {
+		switch i % 5 {
+		case 0:
+			fmt.Fprintf(&b, "  GET http://host%d.io/path?id=%d 200\r\n", i, i)
+		case 1:
+			b.WriteString("\n")
+		case 2:
+			fmt.Fprintf(&b, "%s https://long.io/%d\n", strings.Repeat("длинная строка ", 20), i)
+		default:
+			fmt.Fprintf(&b, "line %d без ссылок\n", i)
+		}
+	}
+	b.WriteString("tail http://tail.io")
+	return b.String()
+}
+
+func TestMaskChunked(t *testing.T) {
+	dir := t.TempDir()
+	input := chunkedInput(1000)
+	inputPath := filepath.Join(dir, "in.txt")
+	require.NoError(t, os.WriteFile(inputPath, []byte(input), 0644))
+
+	expectedPath := filepath.Join(dir, "expected.txt")
+	svc := NewService(NewFileProducer(inputPath), NewFilePresenter(expectedPath))
+	require.NoError(t, svc.Run(context.Background()))
+	expected, err := os.ReadFile(expectedPath)
+	require.NoError(t, err)
+
+	for _, chunkSize := range []int{1, 7, 100, 4096, 0} {
+		t.Run(fmt.Sprintf("кусок %d", chunkSize), func(t *testing.T) {
+			var out bytes.Buffer
+			report, err := MaskChunked(context.Background(), strings.NewReader(input), int64(len(input)), &out,
+				ChunkConfig{Workers: 4, ChunkSize: chunkSize})
+			require.NoError(t, err)
+			assert.Equal(t, string(expected), out.String())
+			assert.Equal(t, svc.Report().Segments, report.Segments)
+			assert.Equal(t, svc.Report().Masked, report.Masked)
+		})
+	}
+
+	t.Run("пустой вход", func(t *testing.T) {
+		var out bytes.Buffer
+		report, err := MaskChunked(context.Background(), strings.NewReader(""), 0, &out, ChunkConfig{})
+		require.NoError(t, err)
+		assert.Empty(t, out.String())
+		assert.Zero(t, report.Segments)
+	})
+}
+
+func TestMaskChunked_Cancel(t *testing.T) {
+	input := chunkedInput(2000)
+	ctx, cancel := context.WithCancel(context.Background())
+	masker := masking.NewLinkMasker()
+	masker.AddRule(cancelRule{stop: "line 1003 без ссылок", cancel: cancel}, nil)
+
+	var full, partial bytes.Buffer
+	_, err := MaskChunked(context.Background(), strings.NewReader(input), int64(len(input)), &full, ChunkConfig{Workers: 4})
+	require.NoError(t, err)
+
+	_, err = MaskChunked(ctx, strings.NewReader(input), int64(len(input)), &partial,
+		ChunkConfig{Workers: 4, ChunkSize: 512, Masker: masker})
+	require.ErrorIs(t, err, context.Canceled)
+	assert.True(t, strings.HasPrefix(full.String(), partial.String()), "записаны только целые куски по порядку")
+	assert.Less(t, partial.Len(), full.Len())
+}
+
+func TestChunkEnd(t *testing.T) {
+	data := "ab\ncd\n" + strings.Repeat("x", 5000) + "\nz"
+	r := strings.NewReader(data)
+	size := int64(len(data))
+
+	end, err := chunkEnd(r, 0, size, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), end, "кусок продлевается до конца строки")
+
+	end, err = chunkEnd(r, 0, size, 3)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), end, "перевод строки на границе остается в куске")
+
+	end, err = chunkEnd(r, 3, size, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(6+5000+1), end, "длинная строка за пределами окна")
+
+	end, err = chunkEnd(r, 5008, size, 1)
+	require.NoError(t, err)
+	assert.Equal(t, size, end)
+}
+
+func TestReport_Allowed(t *testing.T) {
+	masker := masking.NewLinkMasker()
+	filter, err := masking.NewDomainFilter([]string{"github.com"}, nil)
+	require.NoError(t, err)
+	require.NoError(t, masker.SetDomainFilter("link", filter))
+
+	input := "https://github.com/a http://b.io\nhttps://github.com\nтекст\n"
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "in.txt")
+	require.NoError(t, os.WriteFile(inputPath, []byte(input), 0644))
+
+	svc := NewService(NewFileProducer(inputPath), NewFilePresenter(filepath.Join(dir, "out.txt")))
+	svc.SetMasker(masker)
+	require.NoError(t, svc.Run(context.Background()))
+	assert.Equal(t, 1, svc.Report().Masked)
+	assert.Equal(t, 2, svc.Report().Allowed)
+
+	var out bytes.Buffer
+	report, err := MaskChunked(context.Background(), strings.NewReader(input), int64(len(input)), &out,
+		ChunkConfig{ChunkSize: 1, Masker: masker})
+	require.NoError(t, err)
+	assert.Equal(t, "https://github.com/a http://****\nhttps://github.com\nтекст", out.String())
+	assert.Equal(t, 1, report.Masked)
+	assert.Equal(t, 2, report.Allowed)
+}
+
+func TestPlaceholders_Deterministic(t *testing.T) {
+	var b strings.Builder
+	for i := range 300 {
+		fmt.Fprintf(&b, "строка %d http://host%d.io и http://host%d.io\n", i, (i*7)%40, i%5)
+	}
+	input := b.String()
+	dir := t.TempDir()
+	inputPath := filepath.Join(dir, "in.txt")
+	require.NoError(t, os.WriteFile(inputPath, []byte(input), 0644))
+
+	// Эталон - маскировка строк по одной, по порядку
+	newMasker := func() *masking.Masker {
+		masker, err := masking.ParseRules("link", "numbered", nil)
+		require.NoError(t, err)
+		return masker
+	}
+	reference := newMasker()
+	var expected []string
+	for _, line := range strings.Split(strings.TrimSuffix(input, "\n"), "\n") {
+		expected = append(expected, reference.Mask(line))
+	}
+
+	for run := range 3 {
+		masker := newMasker()
+		outputPath := filepath.Join(dir, fmt.Sprintf("out%d.txt", run))
+		svc := NewService(NewFileProducer(inputPath), NewFilePresenter(outputPath))
+		svc.SetMasker(masker)
+		svc.SetWorkers(8)
+		require.NoError(t, svc.Run(context.Background()))
+
+		out, err := os.ReadFile(outputPath)
+		require.NoError(t, err)
+		assert.Equal(t, strings.Join(expected, "\n"), string(out), "номера по первому появлению, а не по воркерам")
+		assert.Equal(t, reference.Placeholders(), masker.Placeholders())
+	}
+
+	masker := newMasker()
+	var out bytes.Buffer
+	_, err := MaskChunked(context.Background(), strings.NewReader(input), int64(len(input)), &out,
+		ChunkConfig{Workers: 8, ChunkSize: 64, Masker: masker})
+	require.NoError(t, err)
+	assert.Equal(t, strings.Join(expected, "\n"), out.String())
+
+	mapPath := filepath.Join(dir, "map.json")
+	require.NoError(t, WritePlaceholderMap(mapPath, masker.Placeholders()))
+	info, err := os.Stat(mapPath)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	data, err := os.ReadFile(mapPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"placeholder": "[LINK-1]"`)
+}
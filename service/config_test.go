@@ -0,0 +1,142 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func TestYAMLParser(t *testing.T) {
+	input := `# конфиг для http://internal.io
+services:
+  api:
+    url: https://api.internal/v1   # основной http://backup.io
+    name: "сервис: http://doc.io"
+    retries: 3
+  hook:
+    url: 'http://hook.io/x'
+    notes: |
+      см. http://wiki.io/page
+      # не комментарий http://b.io
+anchors:
+  - &base http://base.io
+  - *base
+`
+	expected := `# конфиг для http://***********
+services:
+  api:
+    url: https://***************   # основной http://*********
+    name: "сервис: http://******"
+    retries: 3
+  hook:
+    url: 'http://*********'
+    notes: |
+      см. http://************
+      # не комментарий http://****
+anchors:
+  - &base http://*******
+  - *base
+`
+
+	result := maskDocument(t, newYAMLParser(DefaultFormatOptions()), input)
+	assert.Equal(t, expected, result)
+
+	var check yaml.Node
+	assert.NoError(t, yaml.Unmarshal([]byte(result), &check))
+}
+
+func TestYAMLParser_KeyPaths(t *testing.T) {
+	opts := DefaultFormatOptions()
+	opts.KeyPaths = []string{"services.*.url"}
+
+	input := "services:\n  a:\n    url: http://a.io\n    doc: http://doc.io\nurl: http://root.io\n---\nservices: {b: {url: \"http://b.io\"}}\n"
+	expected := "services:\n  a:\n    url: http://****\n    doc: http://doc.io\nurl: http://root.io\n---\nservices: {b: {url: \"http://****\"}}\n"
+	assert.Equal(t, expected, maskDocument(t, newYAMLParser(opts), input))
+}
+
+func TestYAMLParser_MultilinePlain(t *testing.T) {
+	input := "description: документация\n  лежит на https://wiki.io/page\n  и в архиве\nnext: http://n.io\n"
+	expected := "description: документация\n  лежит на https://************\n  и в архиве\nnext: http://****\n"
+
+	result := maskDocument(t, newYAMLParser(DefaultFormatOptions()), input)
+	assert.Equal(t, expected, result)
+
+	var check map[string]string
+	assert.NoError(t, yaml.Unmarshal([]byte(result), &check))
+	assert.Equal(t, "документация лежит на https://************ и в архиве", check["description"])
+}
+
+func TestYAMLPlainSafe(t *testing.T) {
+	assert.True(t, yamlPlainSafe("https://****"))
+	assert.False(t, yamlPlainSafe("[URL#1]"))
+	assert.False(t, yamlPlainSafe("a: b"))
+}
+
+func TestTOMLParser(t *testing.T) {
+	input := `# общий конфиг http://internal.io
+title = "http://title.io"
+
+[services.api]
+url = "https://api.internal/v1" # резерв http://backup.io
+port = 8080
+mirrors = [
+  "http://m1.io",  # первый
+  'http://m2.io',
+]
+description = """
+Документация: http://wiki.io
+"""
+
+[[hooks]]
+url = 'http://hook.io'
+`
+	expected := `# общий конфиг http://***********
+title = "http://********"
+
+[services.api]
+url = "https://***************" # резерв http://*********
+port = 8080
+mirrors = [
+  "http://*****",  # первый
+  'http://*****',
+]
+description = """
+Документация: http://*******
+"""
+
+[[hooks]]
+url = 'http://*******'
+`
+	assert.Equal(t, expected, maskDocument(t, newTOMLParser(DefaultFormatOptions()), input))
+}
+
+func TestTOMLParser_KeyPaths(t *testing.T) {
+	opts := DefaultFormatOptions()
+	opts.KeyPaths = []string{"services.*.url", "hooks[*].url"}
+
+	input := "url = \"http://root.io\"\n[services.a]\nurl = \"http://a.io\"\ndoc = \"http://doc.io\"\n[[hooks]]\n\"url\" = \"http://h.io\"\n"
+	expected := "url = \"http://root.io\"\n[services.a]\nurl = \"http://****\"\ndoc = \"http://doc.io\"\n[[hooks]]\n\"url\" = \"http://****\"\n"
+	assert.Equal(t, expected, maskDocument(t, newTOMLParser(opts), input))
+}
+
+func TestTOMLParser_InlineKeyPaths(t *testing.T) {
+	opts := DefaultFormatOptions()
+	opts.KeyPaths = []string{"a.url", "list[1]", "nested[*].u"}
+
+	input := `a = { url = "http://a.io", doc = "http://doc.io" }
+list = ["http://l0.io", "http://l1.io"]
+nested = [
+  { u = "http://n.io", v = { u = "http://v.io" } },
+  { u = 'http://m.io' },
+]
+`
+	expected := `a = { url = "http://****", doc = "http://doc.io" }
+list = ["http://l0.io", "http://*****"]
+nested = [
+  { u = "http://****", v = { u = "http://v.io" } },
+  { u = 'http://****' },
+]
+`
+	assert.Equal(t, expected, maskDocument(t, newTOMLParser(opts), input))
+}
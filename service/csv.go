@@ -0,0 +1,175 @@
+package service
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// csvParser разбирает CSV/TSV через encoding/csv, а исходные границы полей
+// берет из Reader.FieldPos. Поэтому кавычки, разделители и переводы строк
+// остаются как в исходном файле, а поле в кавычках с переносом строки
+// считается одной записью.
+type csvParser struct {
+	opts      FormatOptions
+	delimiter rune
+	src       string
+	doc       *document
+	literal   int // начало еще не добавленного в документ фрагмента разметки
+}
+
+func newCSVParser(opts FormatOptions, defaultDelimiter rune) documentParser {
+	return func(data []byte) (*document, error) {
+		delimiter, err := parseDelimiter(opts.CSVDelimiter, defaultDelimiter)
+		if err != nil {
+			return nil, err
+		}
+
+		p := &csvParser{opts: opts, delimiter: delimiter, src: string(data), doc: &document{}}
+		if err := p.parse(); err != nil {
+			return nil, err
+		}
+		return p.doc, nil
+	}
+}
+
+func (p *csvParser) parse() error {
+	reader := csv.NewReader(strings.NewReader(p.src))
+	reader.Comma = p.delimiter
+	reader.FieldsPerRecord = -1
+
+	lineStarts := []int{0}
+	for i := 0; i < len(p.src); i++ {
+		if p.src[i] == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+
+	var columns map[int]bool
+	first := true
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if first {
+			first = false
+			columns, err = p.selectColumns(record)
+			if err != nil {
+				return err
+			}
+			if p.opts.CSVHeader {
+				continue
+			}
+		}
+
+		for i, value := range record {
+			if columns != nil && !columns[i] {
+				continue
+			}
+			line, column := reader.FieldPos(i)
+			p.field(lineStarts[line-1]+column-1, value)
+		}
+	}
+
+	p.doc.addText(p.src[p.literal:])
+	return nil
+}
+
+// selectColumns - индексы выбранных колонок, nil - все колонки.
+// Колонка задается именем из заголовка или номером с единицы.
+func (p *csvParser) selectColumns(first []string) (map[int]bool, error) {
+	if len(p.opts.CSVColumns) == 0 {
+		return nil, nil
+	}
+
+	columns := make(map[int]bool)
+	for _, spec := range p.opts.CSVColumns {
+		spec = strings.TrimSpace(spec)
+		found := false
+		if p.opts.CSVHeader {
+			for i, name := range first {
+				if strings.TrimSpace(name) == spec {
+					columns[i] = true
+					found = true
+				}
+			}
+		}
+		if found {
+			continue
+		}
+
+		number, err := strconv.Atoi(spec)
+		if err != nil || number < 1 {
+			return nil, fmt.Errorf("колонка %q не найдена", spec)
+		}
+		columns[number-1] = true
+	}
+	return columns, nil
+}
+
+// field отдает значение поля на маскировку. start - смещение начала поля в исходном
+// тексте (для поля в кавычках - позиция открывающей кавычки).
+func (p *csvParser) field(start int, value string) {
+	if value == "" {
+		return
+	}
+
+	quoted := p.src[start] == '"'
+	end := start + len(value)
+	if quoted {
+		end = closingQuote(p.src, start)
+	}
+	raw := p.src[start:end]
+
+	p.doc.addText(p.src[p.literal:start])
+	p.doc.addEncodedSegment(value, func(masked, original string) string {
+		if masked == original {
+			return raw
+		}
+		if quoted || strings.ContainsAny(masked, string(p.delimiter)+"\"\r\n") {
+			return `"` + strings.ReplaceAll(masked, `"`, `""`) + `"`
+		}
+		return masked
+	})
+	p.literal = end
+}
+
+// closingQuote возвращает позицию сразу после закрывающей кавычки поля
+func closingQuote(s string, start int) int {
+	for i := start + 1; i < len(s); i++ {
+		if s[i] != '"' {
+			continue
+		}
+		if i+1 < len(s) && s[i+1] == '"' {
+			i++
+			continue
+		}
+		return i + 1
+	}
+	return len(s)
+}
+
+// parseDelimiter понимает один символ, а также "\t" и "tab"
+func parseDelimiter(spec string, fallback rune) (rune, error) {
+	switch spec {
+	case "":
+		return fallback, nil
+	case `\t`, "tab":
+		return '\t', nil
+	}
+
+	r, size := utf8.DecodeRuneInString(spec)
+	if size != len(spec) || r == '"' || r == '\r' || r == '\n' || r == utf8.RuneError {
+		return 0, fmt.Errorf("некорректный разделитель CSV: %q", spec)
+	}
+	return r, nil
+}
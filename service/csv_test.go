@@ -0,0 +1,76 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCSVParser(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     func(*FormatOptions)
+		input    string
+		expected string
+	}{
+		{
+			name:     "кавычки и разделители сохраняются",
+			input:    "id,url,note\r\n1,http://a.io/x,\"см. http://b.io, там\"\r\n",
+			expected: "id,url,note\r\n1,http://******,\"см. http://***** там\"\r\n",
+		},
+		{
+			name:     "поле в кавычках с переносом строки",
+			input:    "1,\"строка\nhttp://a.io/\"\"q\"\"\",http://c.io\n2,x,y\n",
+			expected: "1,\"строка\nhttp://********\",http://****\n2,x,y\n",
+		},
+		{
+			name:     "лишние кавычки у поля без ссылок не пропадают",
+			input:    "\"a\",\"http://a.io\"\n",
+			expected: "\"a\",\"http://****\"\n",
+		},
+		{
+			name: "колонки по имени из заголовка",
+			opts: func(o *FormatOptions) {
+				o.CSVHeader = true
+				o.CSVColumns = []string{"site"}
+			},
+			input:    "http://header.io,site\nhttp://a.io,http://b.io\n",
+			expected: "http://header.io,site\nhttp://a.io,http://****\n",
+		},
+		{
+			name:     "колонки по номеру и другой разделитель",
+			opts:     func(o *FormatOptions) { o.CSVDelimiter = ";"; o.CSVColumns = []string{"1"} },
+			input:    "http://a.io;http://b.io\n",
+			expected: "http://****;http://b.io\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opts := DefaultFormatOptions()
+			if test.opts != nil {
+				test.opts(&opts)
+			}
+			assert.Equal(t, test.expected, maskDocument(t, newCSVParser(opts, ','), test.input))
+		})
+	}
+}
+
+func TestCSVParser_TSV(t *testing.T) {
+	input := "a b\thttp://a.io c\n"
+	expected := "a b\thttp://**** c\n"
+	assert.Equal(t, expected, maskDocument(t, newCSVParser(DefaultFormatOptions(), '\t'), input))
+}
+
+func TestCSVParser_Errors(t *testing.T) {
+	opts := DefaultFormatOptions()
+	opts.CSVColumns = []string{"нет такой"}
+	_, err := newCSVParser(opts, ',')([]byte("a,b\n"))
+	assert.Error(t, err)
+
+	_, err = newCSVParser(DefaultFormatOptions(), ',')([]byte("a,\"b\n"))
+	assert.Error(t, err)
+
+	_, err = parseDelimiter(";;", ',')
+	assert.Error(t, err)
+}
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// document - разобранный структурированный файл: чередование неизменяемых
+// фрагментов разметки и сегментов, которые уходят воркерам на маскировку.
+// Producer разбирает файл в document, Presenter собирает его обратно
+// из замаскированных сегментов, не трогая разметку.
+type document struct {
+	parts    []docPart
+	segments int
+}
+
+type docPart struct {
+	text     string
+	maskable bool
+	// encode - как вставить замаскированный сегмент обратно (экранирование, кавычки);
+	// nil - вставить как есть
+	encode func(masked, original string) string
+	// sub - вложенный документ (например, часть письма в base64): собирается
+	// отдельно и целиком передается в wrap. Если в нем ничего не замаскировано,
+	// выводится исходный text без перекодирования.
+	sub  *document
+	wrap func(rendered string) string
+}
+
+func (d *document) addText(text string) {
+	if text == "" {
+		return
+	}
+	d.parts = append(d.parts, docPart{text: text})
+}
+
+func (d *document) addSegment(text string) {
+	d.addEncodedSegment(text, nil)
+}
+
+func (d *document) addEncodedSegment(text string, encode func(masked, original string) string) {
+	if text == "" {
+		return
+	}
+	d.parts = append(d.parts, docPart{text: text, maskable: true, encode: encode})
+	d.segments++
+}
+
+func (d *document) addDocument(raw string, sub *document, wrap func(rendered string) string) {
+	d.parts = append(d.parts, docPart{text: raw, sub: sub, wrap: wrap})
+	d.segments += sub.segments
+}
+
+// Segments возвращает сегменты для маскировки в порядке следования в документе
+func (d *document) Segments() []string {
+	return d.appendSegments(make([]string, 0, d.segments))
+}
+
+func (d *document) appendSegments(segments []string) []string {
+	for _, part := range d.parts {
+		switch {
+		case part.sub != nil:
+			segments = part.sub.appendSegments(segments)
+		case part.maskable:
+			segments = append(segments, part.text)
+		}
+	}
+	return segments
+}
+
+// Render собирает документ, подставляя замаскированные сегменты по порядку
+func (d *document) Render(masked []string) ([]byte, error) {
+	if len(masked) != d.segments {
+		return nil, fmt.Errorf("документ обработан не полностью: %d из %d фрагментов", len(masked), d.segments)
+	}
+
+	var b strings.Builder
+	d.render(&b, masked)
+	return []byte(b.String()), nil
+}
+
+func (d *document) render(b *strings.Builder, masked []string) {
+	next := 0
+	for _, part := range d.parts {
+		switch {
+		case part.sub != nil:
+			subMasked := masked[next : next+part.sub.segments]
+			next += part.sub.segments
+			if slices.Equal(subMasked, part.sub.Segments()) {
+				b.WriteString(part.text)
+				continue
+			}
+			var sb strings.Builder
+			part.sub.render(&sb, subMasked)
+			b.WriteString(part.wrap(sb.String()))
+		case part.maskable:
+			value := masked[next]
+			next++
+			if part.encode != nil {
+				value = part.encode(value, part.text)
+			}
+			b.WriteString(value)
+		default:
+			b.WriteString(part.text)
+		}
+	}
+}
+
+// documentParser разбирает содержимое файла определенного формата
+type documentParser func(data []byte) (*document, error)
+
+// DocumentProducer - Producer для структурированных форматов: отдает на маскировку
+// только сегменты документа, разметку оставляет для DocumentPresenter
+type DocumentProducer struct {
+	filePath string
+	parse    documentParser
+	doc      *document
+}
+
+// DocumentPresenter собирает документ, разобранный связанным DocumentProducer
+type DocumentPresenter struct {
+	filePath  string
+	gzipLevel int
+	source    *DocumentProducer
+}
+
+func NewDocumentPair(inputPath, outputPath string, parse documentParser) (*DocumentProducer, *DocumentPresenter) {
+	producer := &DocumentProducer{filePath: inputPath, parse: parse}
+	return producer, &DocumentPresenter{filePath: outputPath, source: producer}
+}
+
+func (producer *DocumentProducer) Produce() ([]string, error) {
+	data, err := readSource(producer.filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := producer.parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка разбора %s: %w", producer.filePath, err)
+	}
+	producer.doc = doc
+
+	return doc.Segments(), nil
+}
+
+func (presenter *DocumentPresenter) Present(lines []string) error {
+	if presenter.source.doc == nil {
+		return fmt.Errorf("документ не был прочитан")
+	}
+
+	data, err := presenter.source.doc.Render(lines)
+	if err != nil {
+		return err
+	}
+	return writeDest(presenter.filePath, data, presenter.gzipLevel)
+}
+
+// SetGzipLevel включает сжатие результата gzip с заданным уровнем (1-9)
+func (presenter *DocumentPresenter) SetGzipLevel(level int) {
+	presenter.gzipLevel = level
+}
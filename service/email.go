@@ -0,0 +1,290 @@
+package service
+
+import (
+	"encoding/base64"
+	"io"
+	"log/slog"
+	"mime"
+	"mime/quotedprintable"
+	"strings"
+)
+
+// Заголовки, которые описывают структуру письма или подписаны отправителем.
+// Их значения не маскируются, чтобы письмо разбиралось так же, как исходное.
+var emailStructuralHeaders = map[string]bool{
+	"content-type":              true,
+	"content-transfer-encoding": true,
+	"content-disposition":       true,
+	"content-id":                true,
+	"mime-version":              true,
+	"message-id":                true,
+	"in-reply-to":               true,
+	"references":                true,
+	"dkim-signature":            true,
+	"arc-seal":                  true,
+	"arc-message-signature":     true,
+}
+
+// base64LineLength - длина строки base64 в письме (RFC 2045)
+const base64LineLength = 76
+
+// emailParser разбирает письмо RFC 5322 или mbox с несколькими письмами.
+// Части text/plain и text/html раскодируются (quoted-printable, base64),
+// маскируются как текст или HTML и кодируются обратно тем же способом.
+// Вложения и заголовки структуры переносятся без изменений.
+type emailParser struct {
+	html documentParser
+	eol  string
+}
+
+func newEmailParser(opts FormatOptions) documentParser {
+	return func(data []byte) (*document, error) {
+		src := string(data)
+		p := &emailParser{html: newHTMLParser(opts), eol: "\n"}
+		if strings.Contains(src, "\r\n") {
+			p.eol = "\r\n"
+		}
+
+		doc := &document{}
+		if !strings.HasPrefix(src, "From ") {
+			p.entity(doc, src)
+			return doc, nil
+		}
+
+		// mbox: письмо начинается со строки "From " в начале файла или после пустой строки
+		for _, message := range splitMbox(src) {
+			fromLine, eol := cutEOL(splitLinesKeepEnds(message)[0])
+			doc.addText(fromLine + eol)
+			p.entity(doc, message[len(fromLine)+len(eol):])
+		}
+		return doc, nil
+	}
+}
+
+func splitMbox(src string) []string {
+	var messages []string
+	start, pos, prevEmpty := 0, 0, true
+	for _, line := range splitLinesKeepEnds(src) {
+		if prevEmpty && pos > start && strings.HasPrefix(line, "From ") {
+			messages = append(messages, src[start:pos])
+			start = pos
+		}
+		content, _ := cutEOL(line)
+		prevEmpty = content == ""
+		pos += len(line)
+	}
+	return append(messages, src[start:])
+}
+
+// entity - письмо или часть multipart: заголовки, пустая строка и тело
+func (p *emailParser) entity(doc *document, raw string) {
+	headerEnd, bodyStart := len(raw), len(raw)
+	pos := 0
+	for _, line := range splitLinesKeepEnds(raw) {
+		if content, _ := cutEOL(line); content == "" && line != "" {
+			headerEnd, bodyStart = pos, pos+len(line)
+			break
+		}
+		pos += len(line)
+	}
+
+	headers := p.headers(doc, raw[:headerEnd])
+	doc.addText(raw[headerEnd:bodyStart])
+	body := raw[bodyStart:]
+
+	mediaType, params := "text/plain", map[string]string{}
+	if value, ok := headers["content-type"]; ok {
+		if parsed, parsedParams, err := mime.ParseMediaType(value); err == nil {
+			mediaType, params = parsed, parsedParams
+		}
+	}
+
+	switch {
+	case strings.HasPrefix(mediaType, "multipart/") && params["boundary"] != "":
+		p.multipart(doc, body, params["boundary"])
+	case mediaType == "message/rfc822":
+		p.entity(doc, body)
+	case mediaType == "text/plain" || mediaType == "text/html":
+		p.textBody(doc, body, mediaType, strings.ToLower(headers["content-transfer-encoding"]))
+	default:
+		doc.addText(body)
+	}
+}
+
+// headers маскирует значения заголовков и возвращает их без переносов для разбора структуры
+func (p *emailParser) headers(doc *document, block string) map[string]string {
+	headers := make(map[string]string)
+
+	var fields []string
+	for _, line := range splitLinesKeepEnds(block) {
+		if len(fields) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			fields[len(fields)-1] += line
+			continue
+		}
+		fields = append(fields, line)
+	}
+
+	unfold := strings.NewReplacer("\r\n", "", "\n", "")
+	for _, field := range fields {
+		value, eol := cutEOL(field)
+		colon := strings.IndexByte(value, ':')
+		if colon <= 0 {
+			doc.addText(field)
+			continue
+		}
+		name := strings.ToLower(strings.TrimSpace(value[:colon]))
+		value = value[colon+1:]
+		headers[name] = strings.TrimSpace(unfold.Replace(value))
+
+		trimmed := strings.TrimLeft(value, " \t")
+		doc.addText(field[:colon+1+len(value)-len(trimmed)])
+		if emailStructuralHeaders[name] {
+			doc.addText(trimmed)
+		} else {
+			p.headerValue(doc, trimmed)
+		}
+		doc.addText(eol)
+	}
+	return headers
+}
+
+// headerValue - значение заголовка; закодированные слова (=?utf-8?B?...?=)
+// раскодируются и после маскировки кодируются заново
+func (p *emailParser) headerValue(doc *document, raw string) {
+	if strings.Contains(raw, "=?") {
+		decoded, err := new(mime.WordDecoder).DecodeHeader(raw)
+		if err == nil && decoded != raw {
+			doc.addEncodedSegment(decoded, func(masked, original string) string {
+				if masked == original {
+					return raw
+				}
+				return mime.QEncoding.Encode("utf-8", masked)
+			})
+			return
+		}
+	}
+
+	// Адреса в угловых скобках (List-Unsubscribe: <https://...>, <mailto:...>):
+	// ссылка заканчивается на '>', скобки не маскируются
+	for {
+		open := strings.IndexByte(raw, '<')
+		if open < 0 {
+			break
+		}
+		closing := strings.IndexByte(raw[open:], '>')
+		if closing < 0 {
+			break
+		}
+		closing += open
+		doc.addSegment(raw[:open])
+		doc.addText("<")
+		doc.addSegment(raw[open+1 : closing])
+		doc.addText(">")
+		raw = raw[closing+1:]
+	}
+	doc.addSegment(raw)
+}
+
+// multipart разбирает части между разделителями "--boundary". Перевод строки
+// перед разделителем относится к разделителю, а не к части.
+func (p *emailParser) multipart(doc *document, body, boundary string) {
+	delimiter := "--" + boundary
+	partStart, pos := -1, 0
+	for _, line := range splitLinesKeepEnds(body) {
+		content, _ := cutEOL(line)
+		content = strings.TrimRight(content, " \t")
+		if content != delimiter && content != delimiter+"--" {
+			pos += len(line)
+			continue
+		}
+
+		if partStart < 0 {
+			doc.addText(body[:pos])
+		} else {
+			part, eol := cutEOL(body[partStart:pos])
+			p.entity(doc, part)
+			doc.addText(eol)
+		}
+		doc.addText(line)
+		pos += len(line)
+
+		if content == delimiter+"--" {
+			doc.addText(body[pos:])
+			return
+		}
+		partStart = pos
+	}
+
+	if partStart < 0 {
+		doc.addText(body)
+	} else {
+		p.entity(doc, body[partStart:])
+	}
+}
+
+// textBody раскодирует текстовую часть, разбирает как текст или HTML
+// и кодирует обратно при изменениях. Переводы строк в конце тела не кодируются.
+func (p *emailParser) textBody(doc *document, body, mediaType, transferEncoding string) {
+	content := strings.TrimRight(body, "\r\n")
+	trailing := body[len(content):]
+
+	parse := parseText
+	if mediaType == "text/html" {
+		parse = p.html
+	}
+
+	var decoded string
+	var wrap func(string) string
+	switch transferEncoding {
+	case "quoted-printable":
+		data, err := io.ReadAll(quotedprintable.NewReader(strings.NewReader(content)))
+		if err != nil {
+			slog.Warn("некорректный quoted-printable, часть маскируется без раскодирования", "error", err)
+			break
+		}
+		decoded, wrap = string(data), p.encodeQuotedPrintable
+	case "base64":
+		data, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(content), ""))
+		if err != nil {
+			slog.Warn("некорректный base64, часть маскируется без раскодирования", "error", err)
+			break
+		}
+		decoded, wrap = string(data), p.encodeBase64
+	}
+
+	if wrap == nil {
+		content, decoded, trailing = body, body, ""
+		wrap = func(rendered string) string { return rendered }
+	}
+
+	sub, err := parse([]byte(decoded))
+	if err != nil {
+		doc.addText(body)
+		return
+	}
+	doc.addDocument(content, sub, wrap)
+	doc.addText(trailing)
+}
+
+func (p *emailParser) encodeQuotedPrintable(text string) string {
+	var b strings.Builder
+	writer := quotedprintable.NewWriter(&b)
+	writer.Write([]byte(text))
+	writer.Close()
+
+	// quotedprintable.Writer всегда пишет CRLF
+	if p.eol != "\r\n" {
+		return strings.ReplaceAll(b.String(), "\r\n", p.eol)
+	}
+	return b.String()
+}
+
+func (p *emailParser) encodeBase64(text string) string {
+	encoded := base64.StdEncoding.EncodeToString([]byte(text))
+	lines := make([]string, 0, len(encoded)/base64LineLength+1)
+	for len(encoded) > base64LineLength {
+		lines = append(lines, encoded[:base64LineLength])
+		encoded = encoded[base64LineLength:]
+	}
+	return strings.Join(append(lines, encoded), p.eol)
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmailParser_Multipart(t *testing.T) {
+	html := base64.StdEncoding.EncodeToString([]byte(`<p>Ссылка: <a href="https://b.io/x">тут</a></p>`))
+	input := strings.Join([]string{
+		"From: Support <support@example.com>",
+		"Subject: =?utf-8?B?" + base64.StdEncoding.EncodeToString([]byte("Отчет http://a.io")) + "?=",
+		"List-Unsubscribe: <https://c.io/unsub>",
+		"MIME-Version: 1.0",
+		`Content-Type: multipart/alternative; boundary="b1"`,
+		"",
+		"preamble",
+		"--b1",
+		"Content-Type: text/plain; charset=utf-8",
+		"Content-Transfer-Encoding: quoted-printable",
+		"",
+		"=D0=A1=D0=BC=D0=BE=D1=82=D1=80=D0=B8 https://a.io/very/long/path/that/is/=",
+		"split?x=3D1 end",
+		"--b1",
+		"Content-Type: text/html; charset=utf-8",
+		"Content-Transfer-Encoding: base64",
+		"",
+		html,
+		"--b1",
+		"Content-Type: image/png",
+		"Content-Transfer-Encoding: base64",
+		"",
+		"aHR0cDovL2QuaW8=",
+		"--b1--",
+		"",
+	}, "\r\n")
+
+	result := maskDocument(t, newEmailParser(DefaultFormatOptions()), input)
+
+	msg, err := mail.ReadMessage(strings.NewReader(result))
+	require.NoError(t, err)
+	assert.Equal(t, "Support <support@example.com>", msg.Header.Get("From"))
+	assert.Equal(t, "<https://**********>", msg.Header.Get("List-Unsubscribe"))
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	require.NoError(t, err)
+	assert.Equal(t, "Отчет http://****", subject)
+
+	_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+
+	part, err := reader.NextPart()
+	require.NoError(t, err)
+	text, err := io.ReadAll(part) // multipart.Reader сам раскодирует quoted-printable
+	require.NoError(t, err)
+	assert.Equal(t, "Смотри https://"+strings.Repeat("*", 37)+" end", string(text))
+
+	part, err = reader.NextPart()
+	require.NoError(t, err)
+	raw, err := io.ReadAll(part)
+	require.NoError(t, err)
+	decoded, err := base64.StdEncoding.DecodeString(string(raw))
+	require.NoError(t, err)
+	assert.Equal(t, `<p>Ссылка: <a href="https://******">тут</a></p>`, string(decoded))
+
+	part, err = reader.NextPart()
+	require.NoError(t, err)
+	raw, err = io.ReadAll(part)
+	require.NoError(t, err)
+	assert.Equal(t, "aHR0cDovL2QuaW8=", string(raw), "вложения не меняются")
+
+	assert.Contains(t, result, "\r\npreamble\r\n--b1\r\n")
+}
+
+func TestEmailParser_AngleBrackets(t *testing.T) {
+	input := "List-Unsubscribe: <https://x>, <mailto:y>\r\nList-Help: <http://h.io/help>\r\n\r\nbody\r\n"
+	expected := "List-Unsubscribe: <https://*>, <mailto:y>\r\nList-Help: <http://*********>\r\n\r\nbody\r\n"
+	assert.Equal(t, expected, maskDocument(t, newEmailParser(DefaultFormatOptions()), input))
+}
+
+func TestEmailParser_Unchanged(t *testing.T) {
+	var qp strings.Builder
+	w := quotedprintable.NewWriter(&qp)
+	_, err := w.Write([]byte("Привет, без ссылок. Очень длинная строка, которую разобьют мягкими переносами строк."))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	input := "Subject: hi\nContent-Transfer-Encoding: quoted-printable\n\n" + qp.String() + "\n"
+	assert.Equal(t, input, maskDocument(t, newEmailParser(DefaultFormatOptions()), input),
+		"без маскировки тело не перекодируется")
+}
+
+func TestEmailParser_Mbox(t *testing.T) {
+	input := "From alice@example.com Mon Mar  4 10:00:00 2024\n" +
+		"Subject: first http://a.io\n" +
+		"\n" +
+		"body http://b.io\n" +
+		"\n" +
+		"From bob@example.com Mon Mar  4 11:00:00 2024\n" +
+		"Subject: second\n" +
+		"Content-Transfer-Encoding: base64\n" +
+		"\n" +
+		base64.StdEncoding.EncodeToString([]byte("see https://c.io/x")) + "\n"
+
+	result := maskDocument(t, newEmailParser(DefaultFormatOptions()), input)
+
+	expected := "From alice@example.com Mon Mar  4 10:00:00 2024\n" +
+		"Subject: first http://****\n" +
+		"\n" +
+		"body http://****\n" +
+		"\n" +
+		"From bob@example.com Mon Mar  4 11:00:00 2024\n" +
+		"Subject: second\n" +
+		"Content-Transfer-Encoding: base64\n" +
+		"\n" +
+		base64.StdEncoding.EncodeToString([]byte("see https://******")) + "\n"
+	assert.Equal(t, expected, result)
+	assert.Equal(t, FormatEmail, DetectFormat("archive/inbox.mbox"))
+}
@@ -1,20 +1,90 @@
 package service
 
+import "LinkMaskirator/masking"
+
 type ServiceFactory struct {
 	_workers  int
 	_slowmode bool //замедление наших воркеров
+	_masker   *masking.Masker
+	_format   Format
+	_options  FormatOptions
 }
 
 func NewServiceFactory(workers int, slowmode bool) *ServiceFactory {
-	return &ServiceFactory{_workers: workers, _slowmode: slowmode}
+	return &ServiceFactory{
+		_workers:  workers,
+		_slowmode: slowmode,
+		_format:   FormatAuto,
+		_options:  DefaultFormatOptions(),
+	}
+}
+
+// SetMasker задает правила маскировки для создаваемых сервисов
+func (f *ServiceFactory) SetMasker(masker *masking.Masker) {
+	f._masker = masker
+}
+
+// SetFormat задает формат входного файла. FormatAuto - определить по расширению.
+func (f *ServiceFactory) SetFormat(format Format, options FormatOptions) error {
+	if err := options.Validate(); err != nil {
+		return err
+	}
+	f._format = format
+	f._options = options
+	return nil
 }
 
 func (f *ServiceFactory) CreateMaskService(inputPath, outputPath string) *Service {
-	producer := NewFileProducer(inputPath)
-	presenter := NewFilePresenter(outputPath)
+	producer, presenter := f.createPair(inputPath, outputPath)
+	if p, ok := presenter.(gzipPresenter); ok {
+		p.SetGzipLevel(f._options.GzipLevel)
+	}
 	svc := NewService(producer, presenter)
 	svc.SetWorkers(f._workers)
 	svc.SetSlowMode(f._slowmode)
+	svc.SetMasker(f._masker)
 
 	return svc
 }
+
+func (f *ServiceFactory) createPair(inputPath, outputPath string) (Producer, Presenter) {
+	format := f._format
+	if format == FormatAuto {
+		format = DetectFormat(inputPath)
+	}
+
+	if format == FormatArchive {
+		return NewArchivePair(inputPath, outputPath, f._options)
+	}
+	if format == FormatOffice {
+		return NewOfficePair(inputPath, outputPath, f._options)
+	}
+	if parse := documentParserFor(format, f._options); parse != nil {
+		return NewDocumentPair(inputPath, outputPath, parse)
+	}
+	return NewFileProducer(inputPath), NewFilePresenter(outputPath)
+}
+
+// documentParserFor - разбор структурированного формата, nil - обычный текст
+func documentParserFor(format Format, options FormatOptions) documentParser {
+	switch format {
+	case FormatMarkdown:
+		return newMarkdownParser(options)
+	case FormatHTML:
+		return newHTMLParser(options)
+	case FormatJSON, FormatJSONL:
+		return newJSONParser(options, format == FormatJSONL)
+	case FormatCSV:
+		return newCSVParser(options, ',')
+	case FormatTSV:
+		return newCSVParser(options, '\t')
+	case FormatYAML:
+		return newYAMLParser(options)
+	case FormatTOML:
+		return newTOMLParser(options)
+	case FormatEmail:
+		return newEmailParser(options)
+	default:
+		return nil
+	}
+}
@@ -1,12 +1,12 @@
 package service
 
 import (
-	"os"
 	"strings"
 )
 
 type FilePresenter struct {
-	filePath string
+	filePath  string
+	gzipLevel int
 }
 
 func NewFilePresenter(path string) *FilePresenter {
@@ -26,6 +26,11 @@ func trimSpaces(lines []string) string {
 
 func (presenter *FilePresenter) Present(lines []string) error {
 	data := trimSpaces(lines)
-	return os.WriteFile(presenter.filePath, []byte(data), 0644)
+	return writeDest(presenter.filePath, []byte(data), presenter.gzipLevel)
 
 }
+
+// SetGzipLevel включает сжатие результата gzip с заданным уровнем (1-9)
+func (presenter *FilePresenter) SetGzipLevel(level int) {
+	presenter.gzipLevel = level
+}
@@ -2,7 +2,6 @@ package service
 
 import (
 	"bufio"
-	"os"
 )
 
 type FileProducer struct {
@@ -14,7 +13,7 @@ func NewFileProducer(path string) *FileProducer {
 }
 
 func (producer *FileProducer) Produce() ([]string, error) {
-	file, err := os.Open(producer.filePath)
+	file, err := openSource(producer.filePath)
 	if err != nil {
 		return nil, err
 	}
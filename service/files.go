@@ -0,0 +1,151 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Сжатие входных и выходных файлов
+const (
+	compressionNone  = ""
+	compressionGzip  = "gzip"
+	compressionZlib  = "zlib"
+	compressionBzip2 = "bzip2"
+)
+
+// compressionExtensions - расширения сжатых файлов. Формат содержимого
+// определяется по расширению перед ними: logs.jsonl.gz -> jsonl
+var compressionExtensions = map[string]string{
+	".gz":   compressionGzip,
+	".gzip": compressionGzip,
+	".zz":   compressionZlib,
+	".zlib": compressionZlib,
+	".bz2":  compressionBzip2,
+}
+
+// gzipPresenter - Presenter, умеющий сжимать результат
+type gzipPresenter interface {
+	SetGzipLevel(level int)
+}
+
+// openSource открывает входной файл и прозрачно распаковывает gzip, zlib и bzip2.
+// Сжатие определяется по расширению или по сигнатуре gzip/bzip2 в начале файла.
+func openSource(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := decompress(file, path)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("ошибка распаковки %s: %w", path, err)
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{reader, file}, nil
+}
+
+// decompress оборачивает поток распаковщиком, если он сжат
+func decompress(r io.Reader, name string) (io.Reader, error) {
+	buffered := bufio.NewReader(r)
+	header, _ := buffered.Peek(4)
+
+	switch detectCompression(name, header) {
+	case compressionGzip:
+		return gzip.NewReader(buffered)
+	case compressionZlib:
+		return zlib.NewReader(buffered)
+	case compressionBzip2:
+		return bzip2.NewReader(buffered), nil
+	default:
+		return buffered, nil
+	}
+}
+
+// detectCompression определяет сжатие по расширению, а без него - по сигнатуре.
+// У zlib сигнатура из двух байт ("x^", "x\x9c"...) встречается и в обычном тексте,
+// поэтому zlib распознается только по расширению .zz/.zlib.
+func detectCompression(name string, header []byte) string {
+	if compression, ok := compressionExtensions[strings.ToLower(filepath.Ext(name))]; ok {
+		return compression
+	}
+
+	switch {
+	case bytes.HasPrefix(header, []byte{0x1f, 0x8b}):
+		return compressionGzip
+	case len(header) >= 4 && bytes.HasPrefix(header, []byte("BZh")) && header[3] >= '1' && header[3] <= '9':
+		return compressionBzip2
+	default:
+		return compressionNone
+	}
+}
+
+// trimCompressionExt убирает расширение сжатия: logs.jsonl.gz -> logs.jsonl
+func trimCompressionExt(path string) string {
+	ext := filepath.Ext(path)
+	if _, ok := compressionExtensions[strings.ToLower(ext)]; ok {
+		return strings.TrimSuffix(path, ext)
+	}
+	return path
+}
+
+// readSource читает входной файл целиком (с распаковкой)
+func readSource(path string) ([]byte, error) {
+	reader, err := openSource(path)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return io.ReadAll(reader)
+}
+
+// writeDest записывает результат в конечный файл. Если задан уровень сжатия
+// или файл называется *.gz, результат сжимается gzip.
+func writeDest(path string, data []byte, gzipLevel int) error {
+	if gzipLevel == 0 && !strings.EqualFold(filepath.Ext(path), ".gz") {
+		return os.WriteFile(path, data, 0644)
+	}
+	if gzipLevel == 0 {
+		gzipLevel = gzip.DefaultCompression
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	writer, err := gzip.NewWriterLevel(file, gzipLevel)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	if _, err := writer.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// ValidateGzipLevel - 0 (сжатие по расширению .gz) или уровень gzip от 1 до 9
+func ValidateGzipLevel(level int) error {
+	if level < 0 || level > gzip.BestCompression {
+		return fmt.Errorf("уровень сжатия gzip должен быть от 1 до 9: %d", level)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// bzip2 из стандартной библиотеки умеет только читать, поэтому файл сжат заранее:
+// "see http://a.io/x\n"
+var bzip2Sample = []byte{
+	0x42, 0x5a, 0x68, 0x39, 0x31, 0x41, 0x59, 0x26, 0x53, 0x59, 0xba, 0x3b, 0x0d, 0xe4, 0x00, 0x00,
+	0x07, 0x59, 0x80, 0x00, 0x10, 0x40, 0x01, 0x80, 0x10, 0x22, 0x60, 0xcc, 0x40, 0x20, 0x00, 0x22,
+	0x11, 0xa1, 0xea, 0x3d, 0x4f, 0x21, 0x00, 0x00, 0x18, 0x00, 0x1f, 0xe7, 0x6b, 0xc5, 0x35, 0x87,
+	0xdb, 0x17, 0x72, 0x45, 0x38, 0x50, 0x90, 0xba, 0x3b, 0x0d, 0xe4,
+}
+
+func gzipBytes(t *testing.T, data string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, err := w.Write([]byte(data))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func gunzipFile(t *testing.T, path string) string {
+	t.Helper()
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	r, err := gzip.NewReader(file)
+	require.NoError(t, err)
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestReadSource_Compressed(t *testing.T) {
+	dir := t.TempDir()
+
+	var zbuf bytes.Buffer
+	zw := zlib.NewWriter(&zbuf)
+	_, err := zw.Write([]byte("see http://a.io/x\n"))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	files := map[string][]byte{
+		"plain.log":    []byte("see http://a.io/x\n"),
+		"app.log.gz":   gzipBytes(t, "see http://a.io/x\n"),
+		"rotated.1":    gzipBytes(t, "see http://a.io/x\n"), // gzip без расширения - по сигнатуре
+		"app.log.zz":   zbuf.Bytes(),
+		"app.log.bz2":  bzip2Sample,
+		"app.log.bin1": bzip2Sample,
+	}
+
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			require.NoError(t, os.WriteFile(path, content, 0644))
+
+			data, err := readSource(path)
+			require.NoError(t, err)
+			assert.Equal(t, "see http://a.io/x\n", string(data))
+		})
+	}
+
+	t.Run("текст, похожий на заголовок zlib", func(t *testing.T) {
+		path := filepath.Join(dir, "formula.txt")
+		require.NoError(t, os.WriteFile(path, []byte("x^2 http://a.io/x\n"), 0644))
+		data, err := readSource(path)
+		require.NoError(t, err)
+		assert.Equal(t, "x^2 http://a.io/x\n", string(data))
+	})
+
+	t.Run("битый gzip", func(t *testing.T) {
+		path := filepath.Join(dir, "broken.gz")
+		require.NoError(t, os.WriteFile(path, []byte("не gzip"), 0644))
+		_, err := readSource(path)
+		assert.Error(t, err)
+	})
+}
+
+func TestServiceFactory_Gzip(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("сжатый JSON Lines в сжатый результат", func(t *testing.T) {
+		input := filepath.Join(dir, "events.jsonl.gz")
+		output := filepath.Join(dir, "masked.jsonl.gz")
+		require.NoError(t, os.WriteFile(input, gzipBytes(t, "{\"u\":\"http://a.io\"}\n"), 0644))
+
+		svc := NewServiceFactory(2, false).CreateMaskService(input, output)
+		require.NoError(t, svc.Run(context.Background()))
+
+		assert.Equal(t, "{\"u\":\"http://****\"}\n", gunzipFile(t, output))
+	})
+
+	t.Run("уровень сжатия задан явно", func(t *testing.T) {
+		input := filepath.Join(dir, "app.log.gz")
+		output := filepath.Join(dir, "masked.log")
+		require.NoError(t, os.WriteFile(input, gzipBytes(t, "http://a.io\nтекст\n"), 0644))
+
+		factory := NewServiceFactory(2, false)
+		opts := DefaultFormatOptions()
+		opts.GzipLevel = gzip.BestCompression
+		require.NoError(t, factory.SetFormat(FormatAuto, opts))
+
+		svc := factory.CreateMaskService(input, output)
+		require.NoError(t, svc.Run(context.Background()))
+
+		assert.Equal(t, "http://****\nтекст", gunzipFile(t, output))
+	})
+
+	t.Run("некорректный уровень", func(t *testing.T) {
+		opts := DefaultFormatOptions()
+		opts.GzipLevel = 12
+		assert.Error(t, NewServiceFactory(1, false).SetFormat(FormatAuto, opts))
+	})
+}
+
+func TestDetectFormat_Compressed(t *testing.T) {
+	assert.Equal(t, FormatJSONL, DetectFormat("logs/events.jsonl.gz"))
+	assert.Equal(t, FormatCSV, DetectFormat("export.csv.bz2"))
+	assert.Equal(t, FormatText, DetectFormat("app.log.gz"))
+}
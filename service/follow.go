@@ -0,0 +1,346 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"time"
+
+	"LinkMaskirator/masking"
+)
+
+// FollowConfig - настройки режима слежения за растущим файлом
+type FollowConfig struct {
+	Source string
+	Dest   string
+	// Checkpoint - файл с позицией в источнике, по умолчанию Dest + ".checkpoint"
+	// (у RunResumable другое имя по умолчанию и другое содержимое)
+	Checkpoint string
+	// PollInterval - как часто проверять, не появились ли новые строки
+	PollInterval time.Duration
+	Masker       *masking.Masker
+}
+
+// fingerprintSize - сколько первых байт источника запоминается, чтобы после
+// перезапуска отличить тот же файл от нового, появившегося после ротации
+const fingerprintSize = 1024
+
+// followCheckpoint - позиция, до которой источник уже замаскирован.
+// DestSize позволяет после сбоя отрезать строки, записанные после
+// последнего сохранения позиции, чтобы они не продублировались.
+type followCheckpoint struct {
+	// Mode - checkpointFollow; позиция другого режима не подходит
+	Mode        string `json:"mode"`
+	Source      string `json:"source"`
+	Offset      int64  `json:"offset"`
+	DestSize    int64  `json:"dest_size"`
+	Fingerprint string `json:"fingerprint"`
+}
+
+// Follow работает как tail -F: дописывает в Dest замаскированные строки,
+// которые появляются в Source, переживает ротацию и усечение источника
+// и продолжает с сохраненной позиции после перезапуска. Незавершенная
+// последняя строка ждет перевода строки. Работает до отмены ctx.
+func Follow(ctx context.Context, cfg FollowConfig) error {
+	if cfg.Masker == nil {
+		cfg.Masker = defaultMasker
+	}
+	if cfg.Checkpoint == "" {
+		cfg.Checkpoint = cfg.Dest + ".checkpoint"
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+
+	f := &follower{cfg: cfg}
+	if err := f.openDest(); err != nil {
+		return err
+	}
+	defer f.close()
+
+	return f.run(ctx)
+}
+
+type follower struct {
+	cfg  FollowConfig
+	dest *os.File
+
+	src     *os.File
+	srcInfo os.FileInfo
+	// offset - позиция в src после последней обработанной строки
+	offset  int64
+	pending []byte
+	buf     []byte
+	// head - первые байты источника для отпечатка
+	head     []byte
+	destSize int64
+}
+
+func (f *follower) openDest() error {
+	cp, err := loadCheckpoint(f.cfg.Checkpoint)
+	if err != nil {
+		return err
+	}
+	if cp != nil && cp.Mode != checkpointFollow {
+		return fmt.Errorf("позиция %s сохранена не для --follow", f.cfg.Checkpoint)
+	}
+	if cp != nil && cp.Source != f.cfg.Source {
+		return fmt.Errorf("позиция %s сохранена для другого источника: %s", f.cfg.Checkpoint, cp.Source)
+	}
+
+	f.dest, err = os.OpenFile(f.cfg.Dest, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("ошибка открытия конечного файла: %w", err)
+	}
+
+	if cp != nil {
+		if info, err := f.dest.Stat(); err != nil || info.Size() < cp.DestSize {
+			slog.Warn("конечный файл короче сохраненной позиции: зеркало строится заново",
+				"dest", f.cfg.Dest, "saved size", cp.DestSize)
+			cp = nil
+		}
+	}
+	if cp == nil {
+		// Без сохраненной позиции зеркало строится с начала источника
+		cp = &followCheckpoint{Source: f.cfg.Source}
+	}
+	if err := f.dest.Truncate(cp.DestSize); err != nil {
+		return fmt.Errorf("ошибка усечения конечного файла: %w", err)
+	}
+	if _, err := f.dest.Seek(cp.DestSize, io.SeekStart); err != nil {
+		return err
+	}
+	f.destSize = cp.DestSize
+	f.offset = cp.Offset
+
+	if err := f.openSource(); err != nil {
+		return err
+	}
+	if f.src == nil {
+		f.offset = 0
+		return nil
+	}
+
+	// Тот же файл: размер не меньше позиции и совпадает начало
+	head, err := readHead(f.src, min(cp.Offset, fingerprintSize))
+	if err != nil {
+		return err
+	}
+	if f.srcInfo.Size() < cp.Offset || fingerprint(head) != cp.Fingerprint {
+		if cp.Offset > 0 {
+			slog.Warn("источник сменился, пока программа не работала: чтение с начала",
+				"source", f.cfg.Source, "saved offset", cp.Offset)
+		}
+		f.offset = 0
+		return nil
+	}
+	f.head = head
+	slog.Info("продолжение с сохраненной позиции", "source", f.cfg.Source, "offset", f.offset)
+	return nil
+}
+
+func (f *follower) close() {
+	if f.src != nil {
+		f.src.Close()
+	}
+	f.dest.Close()
+}
+
+// openSource открывает источник, если он есть; отсутствие файла
+// (например в момент ротации) не ошибка - он будет открыт позже
+func (f *follower) openSource() error {
+	src, err := os.Open(f.cfg.Source)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка открытия источника: %w", err)
+	}
+	info, err := src.Stat()
+	if err != nil {
+		src.Close()
+		return err
+	}
+	f.src, f.srcInfo = src, info
+	f.head = nil
+	return nil
+}
+
+func (f *follower) run(ctx context.Context) error {
+	for {
+		if f.src != nil {
+			if err := f.readAvailable(); err != nil {
+				return err
+			}
+			if err := f.checkRotation(); err != nil {
+				return err
+			}
+		} else if err := f.openSource(); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(f.cfg.PollInterval):
+		}
+	}
+}
+
+// readAvailable дочитывает источник до текущего конца и маскирует полные строки
+func (f *follower) readAvailable() error {
+	if f.buf == nil {
+		f.buf = make([]byte, 64<<10)
+	}
+	start := f.offset + int64(len(f.pending))
+	for {
+		n, err := f.src.ReadAt(f.buf, start)
+		if n > 0 {
+			f.pending = append(f.pending, f.buf[:n]...)
+			start += int64(n)
+			if err := f.flushLines(); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF || n == 0 {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("ошибка чтения источника: %w", err)
+		}
+	}
+}
+
+// flushLines записывает замаскированные полные строки и сохраняет позицию
+func (f *follower) flushLines() error {
+	last := bytes.LastIndexByte(f.pending, '\n')
+	if last < 0 {
+		return nil
+	}
+	return f.write(last + 1)
+}
+
+// write маскирует первые n байт pending, дописывает их в Dest и сохраняет позицию
+func (f *follower) write(n int) error {
+	var out bytes.Buffer
+	data := f.pending[:n]
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			// Хвост без перевода строки из файла, ушедшего в ротацию
+			i = len(data) - 1
+			data = append(data[:len(data):len(data)], '\n')
+		}
+		text, eol := cutEOL(string(data[:i+1]))
+		out.WriteString(f.cfg.Masker.Mask(text))
+		out.WriteString(eol)
+		data = data[i+1:]
+	}
+
+	if _, err := f.dest.Write(out.Bytes()); err != nil {
+		return fmt.Errorf("ошибка записи в конечный файл: %w", err)
+	}
+	// Позиция сохраняется только после того, как строки дошли до диска
+	if err := f.dest.Sync(); err != nil {
+		return err
+	}
+	f.destSize += int64(out.Len())
+
+	if len(f.head) < fingerprintSize {
+		f.head = append(f.head, f.pending[:min(n, fingerprintSize-len(f.head))]...)
+	}
+	f.offset += int64(n)
+	f.pending = append(f.pending[:0], f.pending[n:]...)
+
+	return saveCheckpoint(f.cfg.Checkpoint, followCheckpoint{
+		Source:      f.cfg.Source,
+		Offset:      f.offset,
+		DestSize:    f.destSize,
+		Fingerprint: fingerprint(f.head),
+	})
+}
+
+// checkRotation вызывается, когда источник дочитан до конца: если по пути
+// лежит другой файл - переходим на него, если файл стал короче - читаем сначала
+func (f *follower) checkRotation() error {
+	info, err := os.Stat(f.cfg.Source)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	if err == nil && os.SameFile(info, f.srcInfo) {
+		if info.Size() < f.offset+int64(len(f.pending)) {
+			slog.Warn("источник усечен: чтение с начала", "source", f.cfg.Source, "size", info.Size())
+			f.offset, f.pending, f.head = 0, f.pending[:0], nil
+		}
+		return nil
+	}
+
+	// Строки, дописанные в старый файл уже после переименования, тоже нужны
+	if err := f.readAvailable(); err != nil {
+		return err
+	}
+	// Старый файл дочитан: незавершенная строка больше не допишется
+	if len(f.pending) > 0 {
+		if err := f.write(len(f.pending)); err != nil {
+			return err
+		}
+	}
+	slog.Info("ротация источника", "source", f.cfg.Source)
+	f.src.Close()
+	f.src, f.srcInfo = nil, nil
+	f.offset, f.head = 0, nil
+	return f.openSource()
+}
+
+func readHead(r io.ReaderAt, size int64) ([]byte, error) {
+	head := make([]byte, size)
+	n, err := r.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return head[:n], nil
+}
+
+func fingerprint(head []byte) string {
+	sum := sha256.Sum256(head)
+	return hex.EncodeToString(sum[:])
+}
+
+func loadCheckpoint(path string) (*followCheckpoint, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var cp followCheckpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("ошибка чтения позиции %s: %w", path, err)
+	}
+	return &cp, nil
+}
+
+// saveCheckpoint пишет позицию через временный файл, чтобы сбой
+// посреди записи не оставил ее испорченной
+func saveCheckpoint(path string, cp followCheckpoint) error {
+	cp.Mode = checkpointFollow
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
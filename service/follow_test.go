@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type followRun struct {
+	cancel context.CancelFunc
+	done   chan error
+}
+
+func startFollow(t *testing.T, cfg FollowConfig) *followRun {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	run := &followRun{cancel: cancel, done: make(chan error, 1)}
+	go func() { run.done <- Follow(ctx, cfg) }()
+	t.Cleanup(func() { run.stop(t) })
+	return run
+}
+
+func (r *followRun) stop(t *testing.T) {
+	t.Helper()
+	if r.cancel == nil {
+		return
+	}
+	r.cancel()
+	r.cancel = nil
+	require.NoError(t, <-r.done)
+}
+
+func appendFile(t *testing.T, path, data string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	require.NoError(t, err)
+	_, err = f.WriteString(data)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+}
+
+func waitDest(t *testing.T, path, expected string) {
+	t.Helper()
+	assert.EventuallyWithT(t, func(c *assert.CollectT) {
+		data, _ := os.ReadFile(path)
+		assert.Equal(c, expected, string(data))
+	}, 2*time.Second, 5*time.Millisecond)
+}
+
+func TestFollow(t *testing.T) {
+	dir := t.TempDir()
+	cfg := FollowConfig{
+		Source:       filepath.Join(dir, "app.log"),
+		Dest:         filepath.Join(dir, "masked.log"),
+		PollInterval: 5 * time.Millisecond,
+	}
+	appendFile(t, cfg.Source, "one http://a.io\r\n")
+	run := startFollow(t, cfg)
+	waitDest(t, cfg.Dest, "one http://****\r\n")
+
+	// Незавершенная строка ждет перевода строки
+	appendFile(t, cfg.Source, "two https://b")
+	time.Sleep(30 * time.Millisecond)
+	waitDest(t, cfg.Dest, "one http://****\r\n")
+	appendFile(t, cfg.Source, ".io/x\n")
+	waitDest(t, cfg.Dest, "one http://****\r\ntwo https://******\n")
+
+	t.Run("ротация", func(t *testing.T) {
+		appendFile(t, cfg.Source, "tail http://c.io")
+		require.NoError(t, os.Rename(cfg.Source, cfg.Source+".1"))
+		appendFile(t, cfg.Source, "new http://d.io\n")
+		waitDest(t, cfg.Dest, "one http://****\r\ntwo https://******\ntail http://****\nnew http://****\n")
+	})
+
+	t.Run("усечение", func(t *testing.T) {
+		require.NoError(t, os.Truncate(cfg.Source, 0))
+		time.Sleep(30 * time.Millisecond)
+		appendFile(t, cfg.Source, "again http://e.io\n")
+		waitDest(t, cfg.Dest, "one http://****\r\ntwo https://******\ntail http://****\nnew http://****\nagain http://****\n")
+	})
+
+	t.Run("перезапуск", func(t *testing.T) {
+		run.stop(t)
+		appendFile(t, cfg.Source, "offline http://f.io\n")
+		// Строки, записанные после последней сохраненной позиции, отрезаются
+		appendFile(t, cfg.Dest, "garbage")
+
+		startFollow(t, cfg)
+		waitDest(t, cfg.Dest, "one http://****\r\ntwo https://******\ntail http://****\nnew http://****\n"+
+			"again http://****\noffline http://****\n")
+	})
+}
+
+func TestFollow_RotatedWhileStopped(t *testing.T) {
+	dir := t.TempDir()
+	cfg := FollowConfig{
+		Source:       filepath.Join(dir, "app.log"),
+		Dest:         filepath.Join(dir, "masked.log"),
+		PollInterval: 5 * time.Millisecond,
+	}
+	appendFile(t, cfg.Source, "first http://a.io\n")
+	run := startFollow(t, cfg)
+	waitDest(t, cfg.Dest, "first http://****\n")
+	run.stop(t)
+
+	// Новый файл длиннее сохраненной позиции, но начинается иначе
+	require.NoError(t, os.Remove(cfg.Source))
+	appendFile(t, cfg.Source, "other http://b.io and more text\n")
+
+	startFollow(t, cfg)
+	waitDest(t, cfg.Dest, "first http://****\nother http://**** and more text\n")
+}
+
+func TestFollow_OtherSource(t *testing.T) {
+	dir := t.TempDir()
+	dest := filepath.Join(dir, "masked.log")
+	require.NoError(t, saveCheckpoint(dest+".checkpoint", followCheckpoint{Source: "other.log"}))
+
+	err := Follow(context.Background(), FollowConfig{Source: filepath.Join(dir, "app.log"), Dest: dest})
+	assert.ErrorContains(t, err, "другого источника")
+}
+
+func TestCheckpoint_OtherMode(t *testing.T) {
+	dir := t.TempDir()
+	input := resumeInput(t, dir)
+	dest := filepath.Join(dir, "masked.log")
+	checkpoint := filepath.Join(dir, "position.json")
+
+	require.NoError(t, saveCheckpoint(checkpoint, followCheckpoint{Source: input}))
+	_, err := RunResumable(context.Background(), ResumableConfig{Source: input, Dest: dest, Checkpoint: checkpoint, Resume: true})
+	assert.ErrorContains(t, err, "не для --resume")
+
+	require.NoError(t, os.WriteFile(checkpoint, []byte(`{"mode":"resume","source":"`+input+`"}`), 0644))
+	err = Follow(context.Background(), FollowConfig{Source: input, Dest: dest, Checkpoint: checkpoint})
+	assert.ErrorContains(t, err, "не для --follow")
+}
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Format - формат входного файла, от него зависит пара Producer/Presenter
+type Format string
+
+const (
+	FormatAuto     Format = "auto"
+	FormatText     Format = "text"
+	FormatMarkdown Format = "markdown"
+	FormatHTML     Format = "html"
+	FormatJSON     Format = "json"
+	FormatJSONL    Format = "jsonl"
+	FormatCSV      Format = "csv"
+	FormatTSV      Format = "tsv"
+	FormatYAML     Format = "yaml"
+	FormatTOML     Format = "toml"
+	// FormatArchive - tar (в том числе tar.gz, tgz) или zip, каждый файл внутри
+	// маскируется в своем формате
+	FormatArchive Format = "archive"
+	// FormatOffice - документы DOCX/XLSX/PPTX
+	FormatOffice Format = "office"
+	// FormatEmail - письмо RFC 5322 (.eml) или mbox
+	FormatEmail Format = "email"
+)
+
+// FormatOptions - настройки разбора структурированных форматов
+type FormatOptions struct {
+	// MarkdownCode - что делать с блоками и фрагментами кода (mask|keep)
+	MarkdownCode string
+	// MarkdownLinkText - маскировать текст ссылки, если он сам является URL
+	MarkdownLinkText bool
+	// HTMLNeutralize - заменять замаскированные ссылки в href/src/action на "#masked"
+	HTMLNeutralize bool
+	// JSONInclude, JSONExclude - селекторы полей в стиле JSONPath ($.a.b, items[*].url, $..url).
+	// Без JSONInclude маскируются все строковые значения.
+	JSONInclude []string
+	JSONExclude []string
+	// JSONMalformed - что делать с некорректными записями (text|keep|skip|fail)
+	JSONMalformed string
+	// CSVDelimiter - разделитель полей, пустая строка - по формату ("," для CSV, табуляция для TSV)
+	CSVDelimiter string
+	// CSVHeader - первая строка является заголовком и не маскируется
+	CSVHeader bool
+	// CSVColumns - колонки для маскировки (имя из заголовка или номер с единицы), пусто - все
+	CSVColumns []string
+	// KeyPaths - пути ключей YAML/TOML для маскировки (services.*.url), пусто - все значения
+	KeyPaths []string
+	// ArchiveBinary - что делать с бинарными файлами внутри архива (copy|drop)
+	ArchiveBinary string
+	// GzipLevel - уровень сжатия результата gzip (1-9), 0 - сжимать только файлы *.gz
+	GzipLevel int
+}
+
+func DefaultFormatOptions() FormatOptions {
+	return FormatOptions{
+		MarkdownCode:  CodePolicyMask,
+		JSONMalformed: MalformedText,
+		ArchiveBinary: BinaryCopy,
+	}
+}
+
+func (o FormatOptions) Validate() error {
+	switch o.MarkdownCode {
+	case CodePolicyMask, CodePolicyKeep:
+	default:
+		return fmt.Errorf("неизвестная политика для кода: %q", o.MarkdownCode)
+	}
+
+	switch o.JSONMalformed {
+	case MalformedText, MalformedKeep, MalformedSkip, MalformedFail:
+	default:
+		return fmt.Errorf("неизвестная политика для некорректного JSON: %q", o.JSONMalformed)
+	}
+	if _, err := parseJSONSelectors(o.JSONInclude); err != nil {
+		return err
+	}
+	if _, err := parseJSONSelectors(o.JSONExclude); err != nil {
+		return err
+	}
+	if _, err := parseDelimiter(o.CSVDelimiter, ','); err != nil {
+		return err
+	}
+	if _, err := parseJSONSelectors(o.KeyPaths); err != nil {
+		return err
+	}
+	switch o.ArchiveBinary {
+	case BinaryCopy, BinaryDrop:
+	default:
+		return fmt.Errorf("неизвестная политика для бинарных файлов: %q", o.ArchiveBinary)
+	}
+	if err := ValidateGzipLevel(o.GzipLevel); err != nil {
+		return err
+	}
+	return nil
+}
+
+func ParseFormat(name string) (Format, error) {
+	switch Format(strings.ToLower(name)) {
+	case "", FormatAuto:
+		return FormatAuto, nil
+	case FormatText, "txt":
+		return FormatText, nil
+	case FormatMarkdown, "md":
+		return FormatMarkdown, nil
+	case FormatHTML, "htm":
+		return FormatHTML, nil
+	case FormatJSON:
+		return FormatJSON, nil
+	case FormatJSONL, "ndjson":
+		return FormatJSONL, nil
+	case FormatCSV:
+		return FormatCSV, nil
+	case FormatTSV:
+		return FormatTSV, nil
+	case FormatYAML, "yml":
+		return FormatYAML, nil
+	case FormatTOML:
+		return FormatTOML, nil
+	case FormatArchive, "tar", "zip":
+		return FormatArchive, nil
+	case FormatOffice, "docx", "xlsx", "pptx":
+		return FormatOffice, nil
+	case FormatEmail, "eml", "mbox":
+		return FormatEmail, nil
+	default:
+		return "", fmt.Errorf("неизвестный формат: %q", name)
+	}
+}
+
+// DetectFormat определяет формат по расширению файла, по умолчанию - текст.
+// Расширение сжатия не учитывается: logs.jsonl.gz - это JSON Lines, bundle.tar.gz - архив.
+func DetectFormat(path string) Format {
+	switch strings.ToLower(filepath.Ext(trimCompressionExt(path))) {
+	case ".md", ".markdown":
+		return FormatMarkdown
+	case ".html", ".htm", ".xhtml":
+		return FormatHTML
+	case ".json":
+		return FormatJSON
+	case ".jsonl", ".ndjson":
+		return FormatJSONL
+	case ".csv":
+		return FormatCSV
+	case ".tsv", ".tab":
+		return FormatTSV
+	case ".yaml", ".yml":
+		return FormatYAML
+	case ".toml":
+		return FormatTOML
+	case ".zip", ".tar", ".tgz":
+		return FormatArchive
+	case ".docx", ".docm", ".xlsx", ".xlsm", ".pptx", ".pptm":
+		return FormatOffice
+	case ".eml", ".mbox", ".mbx":
+		return FormatEmail
+	default:
+		return FormatText
+	}
+}
@@ -0,0 +1,264 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"LinkMaskirator/masking"
+)
+
+// Finding - чувствительные данные в добавленной строке индекса
+type Finding struct {
+	File string
+	// Line - номер строки в подготовленной к коммиту версии файла, с 1
+	Line int
+	// Rule - правила, нашедшие в строке открытые значения, через запятую
+	Rule string
+	// Masked - строка после маскировки, исходное значение в отчет не попадает
+	Masked string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s:%d: %s: %s", f.File, f.Line, f.Rule, strings.TrimSpace(f.Masked))
+}
+
+// addedLine - строка, добавленная в индекс
+type addedLine struct {
+	file string
+	line int
+	text string
+}
+
+// ScanStaged проверяет только строки, добавленные в индекс репозитория dir
+// (git diff --cached), и возвращает по одной находке на строку в порядке файлов и строк
+func ScanStaged(ctx context.Context, dir string, masker *masking.Masker) ([]Finding, error) {
+	if masker == nil {
+		masker = defaultMasker
+	}
+
+	diff, err := runGit(ctx, dir, nil, "-c", "core.quotePath=false", "diff", "--cached", "--unified=0", "--no-color",
+		"--no-ext-diff", "--no-renames", "--diff-filter=AM", "--src-prefix=a/", "--dst-prefix=b/")
+	if err != nil {
+		return nil, err
+	}
+	added, err := parseAddedLines(bytes.NewReader(diff))
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []Finding
+	for _, line := range added {
+		matches := masker.Find(line.text)
+		var rules []string
+		for _, match := range matches {
+			// Уже замаскированное значение (например после --fix) не мешает коммиту
+			if masker.Masked(line.text, match) || slices.Contains(rules, match.Rule) {
+				continue
+			}
+			rules = append(rules, match.Rule)
+		}
+		if len(rules) == 0 {
+			continue
+		}
+		findings = append(findings, Finding{File: line.file, Line: line.line, Rule: strings.Join(rules, ","),
+			Masked: masker.Replace(line.text, matches)})
+	}
+	return findings, nil
+}
+
+// parseAddedLines разбирает вывод git diff --unified=0: запоминает файл
+// из заголовка "+++ b/..." и номер строки из заголовка блока "@@ -a,b +c,d @@"
+func parseAddedLines(r io.Reader) ([]addedLine, error) {
+	var (
+		result []addedLine
+		file   string
+		line   int
+		inHunk bool
+	)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		text := scanner.Text()
+		switch {
+		case strings.HasPrefix(text, "diff --git "):
+			file, inHunk = "", false
+		case !inHunk && strings.HasPrefix(text, "+++ "):
+			file = diffPath(strings.TrimPrefix(text, "+++ "))
+		case strings.HasPrefix(text, "@@ "):
+			start, err := hunkStart(text)
+			if err != nil {
+				return nil, err
+			}
+			line, inHunk = start, true
+		case inHunk && strings.HasPrefix(text, "+"):
+			if file != "" {
+				result = append(result, addedLine{file: file, line: line, text: text[1:]})
+			}
+			line++
+		}
+	}
+	return result, scanner.Err()
+}
+
+// diffPath убирает префикс "b/" и кавычки, которыми git экранирует необычные имена
+func diffPath(path string) string {
+	// После имени с пробелами git добавляет табуляцию
+	path = strings.TrimRight(path, "\t")
+	if strings.HasPrefix(path, `"`) {
+		if unquoted, err := strconv.Unquote(path); err == nil {
+			path = unquoted
+		}
+	}
+	if path == "/dev/null" {
+		return ""
+	}
+	return strings.TrimPrefix(path, "b/")
+}
+
+// hunkStart - номер первой строки новой версии из "@@ -10,2 +12,3 @@ ..."
+func hunkStart(header string) (int, error) {
+	fields := strings.Fields(header)
+	if len(fields) < 3 || !strings.HasPrefix(fields[2], "+") {
+		return 0, fmt.Errorf("некорректный заголовок блока diff: %q", header)
+	}
+	start, _, _ := strings.Cut(fields[2][1:], ",")
+	n, err := strconv.Atoi(start)
+	if err != nil {
+		return 0, fmt.Errorf("некорректный заголовок блока diff: %q", header)
+	}
+	return n, nil
+}
+
+// FixStaged маскирует найденные строки в индексе и заново добавляет файлы.
+// Рабочая копия исправляется, только если она совпадает с индексом, иначе
+// частично подготовленные изменения пользователя были бы потеряны.
+func FixStaged(ctx context.Context, dir string, masker *masking.Masker, findings []Finding) error {
+	if masker == nil {
+		masker = defaultMasker
+	}
+
+	lines := make(map[string][]int)
+	var files []string
+	for _, f := range findings {
+		if _, ok := lines[f.File]; !ok {
+			files = append(files, f.File)
+		}
+		lines[f.File] = append(lines[f.File], f.Line)
+	}
+
+	for _, file := range files {
+		if err := fixStagedFile(ctx, dir, masker, file, lines[file]); err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+func fixStagedFile(ctx context.Context, dir string, masker *masking.Masker, file string, lineNumbers []int) error {
+	stage, err := runGit(ctx, dir, nil, "ls-files", "--stage", "--", file)
+	if err != nil {
+		return err
+	}
+	// "100644 <sha> 0\t<path>"
+	fields := strings.Fields(string(stage))
+	if len(fields) < 2 {
+		return fmt.Errorf("файл не найден в индексе")
+	}
+	mode := fields[0]
+
+	staged, err := runGit(ctx, dir, nil, "show", ":"+file)
+	if err != nil {
+		return err
+	}
+
+	content := strings.SplitAfter(string(staged), "\n")
+	for i := range content {
+		if !slices.Contains(lineNumbers, i+1) {
+			continue
+		}
+		text, eol := cutEOL(content[i])
+		content[i] = masker.Mask(text) + eol
+	}
+	masked := strings.Join(content, "")
+
+	sha, err := runGit(ctx, dir, strings.NewReader(masked), "hash-object", "-w", "--stdin", "--path", file)
+	if err != nil {
+		return err
+	}
+	cacheInfo := mode + "," + strings.TrimSpace(string(sha)) + "," + file
+	if _, err := runGit(ctx, dir, nil, "update-index", "--cacheinfo", cacheInfo); err != nil {
+		return err
+	}
+
+	path := filepath.Join(dir, filepath.FromSlash(file))
+	working, err := os.ReadFile(path)
+	if err != nil || !bytes.Equal(working, staged) {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(masked), info.Mode().Perm())
+}
+
+// InstallHook записывает pre-commit хук, который запускает command
+// (путь к программе и ее аргументы). Чужой хук без force не перезаписывается.
+func InstallHook(ctx context.Context, dir string, command []string, force bool) (string, error) {
+	hooksDir, err := runGit(ctx, dir, nil, "rev-parse", "--git-path", "hooks")
+	if err != nil {
+		return "", err
+	}
+	path := strings.TrimSpace(string(hooksDir))
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	if err := os.MkdirAll(path, 0o755); err != nil {
+		return "", err
+	}
+	path = filepath.Join(path, "pre-commit")
+
+	if existing, err := os.ReadFile(path); err == nil && !force && !bytes.Contains(existing, []byte(hookMarker)) {
+		return "", fmt.Errorf("хук %s уже существует, для перезаписи укажите --force", path)
+	}
+
+	quoted := make([]string, len(command))
+	for i, arg := range command {
+		quoted[i] = shellQuote(arg)
+	}
+	script := "#!/bin/sh\n" + hookMarker + "\nexec " + strings.Join(quoted, " ") + "\n"
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+const hookMarker = "# Установлено командой git-hook install"
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func runGit(ctx context.Context, dir string, stdin io.Reader, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Stdin = stdin
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
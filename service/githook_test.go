@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"LinkMaskirator/masking"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git не установлен")
+	}
+	dir := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"config", "user.email", "test@example.com"},
+		{"config", "user.name", "test"},
+	} {
+		_, err := runGit(context.Background(), dir, nil, args...)
+		require.NoError(t, err)
+	}
+	return dir
+}
+
+func commitFile(t *testing.T, dir, name, content string, commit bool) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	_, err := runGit(context.Background(), dir, nil, "add", name)
+	require.NoError(t, err)
+	if commit {
+		_, err = runGit(context.Background(), dir, nil, "commit", "-q", "-m", "init")
+		require.NoError(t, err)
+	}
+}
+
+func TestScanStaged(t *testing.T) {
+	ctx := context.Background()
+	dir := newTestRepo(t)
+	commitFile(t, dir, "fixtures/old.txt", "old http://old.io\nkeep\n", true)
+
+	// Уже закоммиченная ссылка не считается, проверяются только добавленные строки
+	commitFile(t, dir, "fixtures/old.txt", "old http://old.io\nkeep\nnew https://new.io/x\n", false)
+	commitFile(t, dir, "с пробелом.txt", "a\nb http://c.io\n", false)
+
+	findings, err := ScanStaged(ctx, dir, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []Finding{
+		{File: "fixtures/old.txt", Line: 3, Rule: "link", Masked: "new https://********"},
+		{File: "с пробелом.txt", Line: 2, Rule: "link", Masked: "b http://****"},
+	}, findings)
+	assert.Equal(t, "fixtures/old.txt:3: link: new https://********", findings[0].String())
+}
+
+func TestScanStaged_OneFindingPerLine(t *testing.T) {
+	ctx := context.Background()
+	dir := newTestRepo(t)
+	commitFile(t, dir, "a.txt", "http://a.io http://b.io +79123456789 http://***\nhttp://**** только маска\n", false)
+
+	masker, err := masking.ParseRules("link,phone", "stars", []string{"ru"})
+	require.NoError(t, err)
+	findings, err := ScanStaged(ctx, dir, masker)
+	require.NoError(t, err)
+	assert.Equal(t, []Finding{
+		{File: "a.txt", Line: 1, Rule: "link,phone", Masked: "http://**** http://**** ************ http://***"},
+	}, findings)
+}
+
+func TestFixStaged(t *testing.T) {
+	ctx := context.Background()
+	dir := newTestRepo(t)
+	commitFile(t, dir, "a.txt", "old http://old.io\n", true)
+	commitFile(t, dir, "a.txt", "old http://old.io\nnew http://new.io\n", false)
+	commitFile(t, dir, "b.txt", "b http://b.io", false)
+	// Рабочая копия b.txt отличается от индекса и не трогается
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.txt"), []byte("b http://b.io\nwip\n"), 0o644))
+
+	findings, err := ScanStaged(ctx, dir, nil)
+	require.NoError(t, err)
+	require.Len(t, findings, 2)
+	require.NoError(t, FixStaged(ctx, dir, nil, findings))
+
+	staged, err := runGit(ctx, dir, nil, "show", ":a.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "old http://old.io\nnew http://******\n", string(staged), "закоммиченные строки не меняются")
+	working, err := os.ReadFile(filepath.Join(dir, "a.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, string(staged), string(working))
+
+	staged, err = runGit(ctx, dir, nil, "show", ":b.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "b http://****", string(staged))
+	working, err = os.ReadFile(filepath.Join(dir, "b.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "b http://b.io\nwip\n", string(working))
+
+	findings, err = ScanStaged(ctx, dir, nil)
+	require.NoError(t, err)
+	assert.Empty(t, findings)
+}
+
+func TestInstallHook(t *testing.T) {
+	ctx := context.Background()
+	dir := newTestRepo(t)
+
+	path, err := InstallHook(ctx, dir, []string{"/opt/link maskirator", "git-hook", "--fix"}, false)
+	require.NoError(t, err)
+	script, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(string(script), "exec '/opt/link maskirator' 'git-hook' '--fix'\n"))
+
+	// Свой хук переустанавливается, чужой - только с force
+	_, err = InstallHook(ctx, dir, []string{"x"}, false)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\nmake lint\n"), 0o755))
+	_, err = InstallHook(ctx, dir, []string{"x"}, false)
+	assert.Error(t, err)
+	_, err = InstallHook(ctx, dir, []string{"x"}, true)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,296 @@
+package service
+
+import (
+	"strings"
+)
+
+// NeutralizedLink - значение, которым заменяется ссылка при FormatOptions.HTMLNeutralize
+const NeutralizedLink = "#masked"
+
+// Атрибуты, значение которых - адрес. Их значения маскируются как ссылки
+// и могут быть нейтрализованы целиком.
+var htmlURLAttributes = map[string]bool{
+	"href":       true,
+	"src":        true,
+	"action":     true,
+	"formaction": true,
+	"poster":     true,
+	"cite":       true,
+	"background": true,
+	"longdesc":   true,
+	"manifest":   true,
+	"ping":       true,
+	"xlink:href": true,
+}
+
+// Элементы, содержимое которых не разбирается как разметка
+var htmlRawTextElements = []string{"script", "style", "textarea", "title", "xmp"}
+
+// htmlParser - токенизатор HTML, сохраняющий исходные байты разметки.
+// На маскировку уходят текст, комментарии и значения атрибутов,
+// теги и кавычки остаются нетронутыми.
+type htmlParser struct {
+	opts FormatOptions
+	doc  *document
+	src  string
+}
+
+func newHTMLParser(opts FormatOptions) documentParser {
+	return func(data []byte) (*document, error) {
+		p := &htmlParser{opts: opts, doc: &document{}, src: string(data)}
+		p.parse()
+		return p.doc, nil
+	}
+}
+
+func (p *htmlParser) parse() {
+	s := p.src
+	textStart := 0
+	for i := 0; i < len(s); {
+		if s[i] != '<' {
+			i++
+			continue
+		}
+
+		var end int
+		var ok bool
+		switch {
+		case strings.HasPrefix(s[i:], "<!--"):
+			p.doc.addSegment(s[textStart:i])
+			end = p.comment(i)
+			ok = true
+		case i+1 < len(s) && (s[i+1] == '!' || s[i+1] == '?'):
+			p.doc.addSegment(s[textStart:i])
+			end = indexFrom(s, i, ">")
+			p.doc.addText(s[i:end])
+			ok = true
+		case i+1 < len(s) && s[i+1] == '/' && i+2 < len(s) && isASCIILetter(s[i+2]):
+			p.doc.addSegment(s[textStart:i])
+			end = indexFrom(s, i, ">")
+			p.doc.addText(s[i:end])
+			ok = true
+		case i+1 < len(s) && isASCIILetter(s[i+1]):
+			p.doc.addSegment(s[textStart:i])
+			end, ok = p.startTag(i), true
+		}
+
+		if !ok {
+			i++
+			continue
+		}
+		i, textStart = end, end
+	}
+	p.doc.addSegment(s[textStart:])
+}
+
+// comment - "<!-- ... -->", текст комментария маскируется
+func (p *htmlParser) comment(start int) int {
+	s := p.src
+	closing := strings.Index(s[start+4:], "-->")
+	if closing < 0 {
+		p.doc.addText("<!--")
+		p.doc.addSegment(s[start+4:])
+		return len(s)
+	}
+	contentEnd := start + 4 + closing
+	p.doc.addText("<!--")
+	p.doc.addSegment(s[start+4 : contentEnd])
+	p.doc.addText("-->")
+	return contentEnd + 3
+}
+
+// startTag разбирает открывающий тег с атрибутами и возвращает позицию после него.
+// Для элементов с "сырым" содержимым (script, style...) сразу забирает и его.
+func (p *htmlParser) startTag(start int) int {
+	s := p.src
+	i := start + 1
+	for i < len(s) && !isHTMLSpace(s[i]) && s[i] != '/' && s[i] != '>' {
+		i++
+	}
+	name := strings.ToLower(s[start+1 : i])
+	p.doc.addText(s[start:i])
+
+	for i < len(s) && s[i] != '>' {
+		literalStart := i
+		for i < len(s) && (isHTMLSpace(s[i]) || s[i] == '/') {
+			i++
+		}
+		nameStart := i
+		for i < len(s) && !isHTMLSpace(s[i]) && s[i] != '/' && s[i] != '>' && s[i] != '=' {
+			i++
+		}
+		attr := strings.ToLower(s[nameStart:i])
+
+		valueStart := i
+		for valueStart < len(s) && isHTMLSpace(s[valueStart]) {
+			valueStart++
+		}
+		if attr == "" || valueStart >= len(s) || s[valueStart] != '=' {
+			p.doc.addText(s[literalStart:i])
+			if attr == "" && i < len(s) && s[i] != '>' {
+				// Одиночный '=' или другой мусор - пропускаем символ как есть
+				p.doc.addText(s[i : i+1])
+				i++
+			}
+			continue
+		}
+
+		i = valueStart + 1
+		for i < len(s) && isHTMLSpace(s[i]) {
+			i++
+		}
+		if i < len(s) && (s[i] == '"' || s[i] == '\'') {
+			quote := s[i]
+			closing := strings.IndexByte(s[i+1:], quote)
+			if closing < 0 {
+				closing = len(s) - i - 1
+			}
+			p.doc.addText(s[literalStart : i+1])
+			p.attributeValue(attr, s[i+1:i+1+closing])
+			i += 1 + closing
+			if i < len(s) {
+				p.doc.addText(s[i : i+1])
+				i++
+			}
+			continue
+		}
+
+		valueEnd := i
+		for valueEnd < len(s) && !isHTMLSpace(s[valueEnd]) && s[valueEnd] != '>' {
+			valueEnd++
+		}
+		p.doc.addText(s[literalStart:i])
+		p.attributeValue(attr, s[i:valueEnd])
+		i = valueEnd
+	}
+
+	end := indexFrom(s, i, ">")
+	p.doc.addText(s[i:end])
+
+	for _, raw := range htmlRawTextElements {
+		if name == raw {
+			return p.rawText(end, name)
+		}
+	}
+	return end
+}
+
+// rawText - содержимое script/style/..., маскируется как обычный текст до закрывающего тега
+func (p *htmlParser) rawText(start int, name string) int {
+	s := p.src
+	closing := strings.Index(strings.ToLower(s[start:]), "</"+name)
+	if closing < 0 {
+		p.rawTextSegments(s[start:])
+		return len(s)
+	}
+	p.rawTextSegments(s[start : start+closing])
+	return start + closing
+}
+
+// rawTextSegments выделяет ссылки внутри кода в отдельные сегменты. В коде ссылка
+// обычно стоит в кавычках или скобках ('...', url(...)), а правило ссылок
+// продолжает ее до пробела и съело бы закрывающую кавычку вместе с остатком строки.
+func (p *htmlParser) rawTextSegments(text string) {
+	last := 0
+	for i := 0; i < len(text); {
+		sep := strings.Index(text[i:], "://")
+		if sep < 0 {
+			break
+		}
+		sep += i
+
+		start := sep
+		for start > last && isSchemeChar(text[start-1]) {
+			start--
+		}
+		end := sep + len("://")
+		for end < len(text) && !isHTMLSpace(text[end]) && !isRawTextLinkEnd(text[end]) {
+			end++
+		}
+		if start == sep {
+			i = end
+			continue
+		}
+
+		p.doc.addSegment(text[last:start])
+		p.doc.addSegment(text[start:end])
+		last, i = end, end
+	}
+	p.doc.addSegment(text[last:])
+}
+
+func (p *htmlParser) attributeValue(attr, value string) {
+	switch {
+	case attr == "srcset":
+		p.srcset(value)
+	case htmlURLAttributes[attr]:
+		p.doc.addEncodedSegment(value, p.neutralize)
+	default:
+		p.doc.addSegment(value)
+	}
+}
+
+// srcset - список "адрес [дескриптор], адрес [дескриптор]", маскируются только адреса
+func (p *htmlParser) srcset(value string) {
+	i := 0
+	for i < len(value) {
+		start := i
+		for i < len(value) && (isHTMLSpace(value[i]) || value[i] == ',') {
+			i++
+		}
+		p.doc.addText(value[start:i])
+
+		urlStart := i
+		for i < len(value) && !isHTMLSpace(value[i]) {
+			i++
+		}
+		url := value[urlStart:i]
+		// Запятая в конце адреса - разделитель кандидатов, если дескриптора нет
+		trailing := len(url) - len(strings.TrimRight(url, ","))
+		p.doc.addEncodedSegment(url[:len(url)-trailing], p.neutralize)
+		i -= trailing
+
+		descStart := i
+		for i < len(value) && value[i] != ',' {
+			i++
+		}
+		p.doc.addText(value[descStart:i])
+	}
+}
+
+// neutralize заменяет замаскированную ссылку на заглушку, если включена опция
+func (p *htmlParser) neutralize(masked, original string) string {
+	if p.opts.HTMLNeutralize && masked != original {
+		return NeutralizedLink
+	}
+	return masked
+}
+
+func indexFrom(s string, from int, sep string) int {
+	idx := strings.Index(s[from:], sep)
+	if idx < 0 {
+		return len(s)
+	}
+	return from + idx + len(sep)
+}
+
+func isHTMLSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r' || b == '\f'
+}
+
+func isSchemeChar(b byte) bool {
+	return isASCIILetter(b) || (b >= '0' && b <= '9') || b == '+' || b == '-' || b == '.'
+}
+
+// isRawTextLinkEnd - символы, на которых заканчивается ссылка внутри script/style
+func isRawTextLinkEnd(b byte) bool {
+	switch b {
+	case '"', '\'', '`', '(', ')', '<', '>':
+		return true
+	}
+	return false
+}
+
+func isASCIILetter(b byte) bool {
+	return (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTMLParser(t *testing.T) {
+	parse := newHTMLParser(DefaultFormatOptions())
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "href не съедает кавычку и закрывающий тег",
+			input:    `<a href="https://x.io/p">https://x.io/p</a>`,
+			expected: `<a href="https://******">https://******</a>`,
+		},
+		{
+			name:     "одинарные кавычки и значение без кавычек",
+			input:    `<img src='http://cdn.io/a.png' alt=logo data-x=http://a.b>`,
+			expected: `<img src='http://************' alt=logo data-x=http://***>`,
+		},
+		{
+			name:     "action формы и пробелы вокруг =",
+			input:    `<FORM ACTION = "https://api.io/send" method="post"></FORM>`,
+			expected: `<FORM ACTION = "https://***********" method="post"></FORM>`,
+		},
+		{
+			name:     "srcset с дескрипторами",
+			input:    `<img srcset="https://a.io/1.png 1x, https://a.io/2.png 2x,https://a.io/3.png">`,
+			expected: `<img srcset="https://********** 1x, https://********** 2x,https://**********">`,
+		},
+		{
+			name:     "комментарии, doctype и скрипты",
+			input:    "<!DOCTYPE html><!-- см. http://old.io --><script>fetch('https://api.io/v1')</script>",
+			expected: "<!DOCTYPE html><!-- см. http://****** --><script>fetch('https://*********')</script>",
+		},
+		{
+			name:     "ссылки в коде заканчиваются на кавычках и скобках",
+			input:    "<script>var u = \"http://a.io/x\"; load(`https://b.io`)</script><style>p{background:url(http://c.io/i.png)}</style>",
+			expected: "<script>var u = \"http://******\"; load(`https://****`)</script><style>p{background:url(http://**********)}</style>",
+		},
+		{
+			name:     "одиночный знак меньше в тексте",
+			input:    "<p>1 < 2 и http://a.ru</p>\n",
+			expected: "<p>1 < 2 и http://****</p>\n",
+		},
+		{
+			name:     "самозакрывающийся тег",
+			input:    `<link rel="icon" href="/favicon.ico"/><br/>`,
+			expected: `<link rel="icon" href="/favicon.ico"/><br/>`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, maskDocument(t, parse, test.input))
+		})
+	}
+}
+
+func TestHTMLParser_Neutralize(t *testing.T) {
+	opts := DefaultFormatOptions()
+	opts.HTMLNeutralize = true
+	parse := newHTMLParser(opts)
+
+	input := `<a href="https://x.io">сайт</a> <a href="/local">тут</a> <img srcset="http://a.io/1.png 2x">`
+	expected := `<a href="#masked">сайт</a> <a href="/local">тут</a> <img srcset="#masked 2x">`
+	assert.Equal(t, expected, maskDocument(t, parse, input))
+}
@@ -0,0 +1,401 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Политики для некорректных записей JSON
+const (
+	MalformedText = "text" // маскировать как обычный текст
+	MalformedKeep = "keep" // оставить без изменений
+	MalformedSkip = "skip" // выбросить запись
+	MalformedFail = "fail" // остановить обработку с ошибкой
+)
+
+// jsonParser разбирает JSON-документ (или каждую строку JSON Lines) без
+// перестроения: форматирование и порядок ключей сохраняются байт в байт.
+// На маскировку уходят раскодированные строковые значения, подходящие
+// под селекторы, обратно они вставляются заново закодированными.
+type jsonParser struct {
+	opts    FormatOptions
+	include []jsonSelector
+	exclude []jsonSelector
+	doc     *document
+}
+
+func newJSONParser(opts FormatOptions, lines bool) documentParser {
+	return func(data []byte) (*document, error) {
+		include, err := parseJSONSelectors(opts.JSONInclude)
+		if err != nil {
+			return nil, err
+		}
+		exclude, err := parseJSONSelectors(opts.JSONExclude)
+		if err != nil {
+			return nil, err
+		}
+
+		p := &jsonParser{opts: opts, include: include, exclude: exclude, doc: &document{}}
+		if !lines {
+			_, err := p.record(string(data), 0)
+			return p.doc, err
+		}
+
+		for n, raw := range splitLinesKeepEnds(string(data)) {
+			line, eol := cutEOL(raw)
+			if strings.TrimSpace(line) == "" {
+				p.doc.addText(raw)
+				continue
+			}
+			kept, err := p.record(line, n+1)
+			if err != nil {
+				return nil, err
+			}
+			if kept {
+				p.doc.addText(eol)
+			}
+		}
+		return p.doc, nil
+	}
+}
+
+// record - одна запись: весь файл для JSON или строка для JSON Lines.
+// Возвращает false, если запись выброшена по политике MalformedSkip.
+func (p *jsonParser) record(text string, lineNumber int) (bool, error) {
+	if !json.Valid([]byte(text)) {
+		switch p.opts.JSONMalformed {
+		case MalformedKeep:
+			p.doc.addText(text)
+		case MalformedSkip:
+			return false, nil
+		case MalformedFail:
+			if lineNumber > 0 {
+				return false, fmt.Errorf("некорректный JSON в строке %d", lineNumber)
+			}
+			return false, fmt.Errorf("некорректный JSON")
+		default:
+			p.doc.addSegment(text)
+		}
+		return true, nil
+	}
+
+	scanner := &jsonScanner{src: text, parser: p}
+	scanner.value(nil)
+	p.doc.addText(text[scanner.literal:])
+	return true, nil
+}
+
+func (p *jsonParser) selected(path []jsonPathElem) bool {
+	for _, sel := range p.exclude {
+		if sel.match(path) {
+			return false
+		}
+	}
+	if len(p.include) == 0 {
+		return true
+	}
+	for _, sel := range p.include {
+		if sel.match(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonScanner обходит заведомо корректный JSON и отмечает строковые значения
+type jsonScanner struct {
+	src     string
+	pos     int
+	literal int // начало еще не добавленного в документ фрагмента разметки
+	parser  *jsonParser
+}
+
+func (s *jsonScanner) value(path []jsonPathElem) {
+	s.skipSpaces()
+	switch s.src[s.pos] {
+	case '{':
+		s.object(path)
+	case '[':
+		s.array(path)
+	case '"':
+		start := s.pos
+		s.skipString()
+		if s.parser.selected(path) {
+			s.stringValue(start, s.pos)
+		}
+	default:
+		for s.pos < len(s.src) && !strings.ContainsRune(",]} \t\r\n", rune(s.src[s.pos])) {
+			s.pos++
+		}
+	}
+}
+
+func (s *jsonScanner) object(path []jsonPathElem) {
+	s.pos++
+	for {
+		s.skipSpaces()
+		if s.src[s.pos] == '}' {
+			s.pos++
+			return
+		}
+
+		keyStart := s.pos
+		s.skipString()
+		var key string
+		_ = json.Unmarshal([]byte(s.src[keyStart:s.pos]), &key)
+
+		s.skipSpaces()
+		s.pos++ // ':'
+		s.value(append(path[:len(path):len(path)], jsonPathElem{key: key, index: -1}))
+
+		s.skipSpaces()
+		if s.src[s.pos] == ',' {
+			s.pos++
+			continue
+		}
+		s.pos++ // '}'
+		return
+	}
+}
+
+func (s *jsonScanner) array(path []jsonPathElem) {
+	s.pos++
+	for index := 0; ; index++ {
+		s.skipSpaces()
+		if s.src[s.pos] == ']' {
+			s.pos++
+			return
+		}
+
+		s.value(append(path[:len(path):len(path)], jsonPathElem{index: index}))
+
+		s.skipSpaces()
+		if s.src[s.pos] == ',' {
+			s.pos++
+			continue
+		}
+		s.pos++ // ']'
+		return
+	}
+}
+
+// stringValue отдает на маскировку раскодированную строку. Если маскировка ничего
+// не изменила, строка остается в исходном виде вместе с экранированием вроде "\/".
+func (s *jsonScanner) stringValue(start, end int) {
+	raw := s.src[start:end]
+	var value string
+	if err := json.Unmarshal([]byte(raw), &value); err != nil || value == "" {
+		return
+	}
+
+	s.parser.doc.addText(s.src[s.literal:start])
+	s.parser.doc.addEncodedSegment(value, func(masked, original string) string {
+		if masked == original {
+			return raw
+		}
+		return encodeJSONString(masked)
+	})
+	s.literal = end
+}
+
+func (s *jsonScanner) skipString() {
+	s.pos++
+	for s.pos < len(s.src) {
+		switch s.src[s.pos] {
+		case '\\':
+			s.pos += 2
+		case '"':
+			s.pos++
+			return
+		default:
+			s.pos++
+		}
+	}
+}
+
+func (s *jsonScanner) skipSpaces() {
+	for s.pos < len(s.src) && strings.ContainsRune(" \t\r\n", rune(s.src[s.pos])) {
+		s.pos++
+	}
+}
+
+// encodeJSONString кодирует строку без экранирования HTML-символов
+func encodeJSONString(value string) string {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	_ = encoder.Encode(value)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// jsonPathElem - ключ объекта или индекс массива (index >= 0)
+type jsonPathElem struct {
+	key   string
+	index int
+}
+
+type selectorKind int
+
+const (
+	selKey selectorKind = iota
+	selAnyKey
+	selIndex
+	selAnyIndex
+	selRecursive
+)
+
+type selectorToken struct {
+	kind  selectorKind
+	key   string
+	index int
+}
+
+// jsonSelector - упрощенный JSONPath: $.a.b, items[*].url, headers.*, $..url.
+// "*" - любое поле объекта, "[*]" - любой элемент массива. Селектор выбирает
+// найденный узел вместе со всем его содержимым.
+type jsonSelector []selectorToken
+
+func parseJSONSelectors(specs []string) ([]jsonSelector, error) {
+	var selectors []jsonSelector
+	for _, spec := range specs {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		sel, err := parseJSONSelector(spec)
+		if err != nil {
+			return nil, err
+		}
+		selectors = append(selectors, sel)
+	}
+	return selectors, nil
+}
+
+func parseJSONSelector(spec string) (jsonSelector, error) {
+	s := strings.TrimPrefix(spec, "$")
+	var sel jsonSelector
+
+	readName := func() string {
+		end := strings.IndexAny(s, ".[")
+		if end < 0 {
+			end = len(s)
+		}
+		name := s[:end]
+		s = s[end:]
+		return name
+	}
+	addName := func(name string) error {
+		switch name {
+		case "":
+			return fmt.Errorf("пустое имя поля в селекторе %q", spec)
+		case "*":
+			sel = append(sel, selectorToken{kind: selAnyKey})
+		default:
+			sel = append(sel, selectorToken{kind: selKey, key: name})
+		}
+		return nil
+	}
+
+	if s != "" && s[0] != '.' && s[0] != '[' {
+		if err := addName(readName()); err != nil {
+			return nil, err
+		}
+	}
+
+	for s != "" {
+		switch {
+		case strings.HasPrefix(s, ".."):
+			s = s[2:]
+			if s == "" {
+				return nil, fmt.Errorf("после '..' ожидается имя поля в селекторе %q", spec)
+			}
+			sel = append(sel, selectorToken{kind: selRecursive})
+			if s[0] != '[' {
+				if err := addName(readName()); err != nil {
+					return nil, err
+				}
+			}
+		case s[0] == '.':
+			s = s[1:]
+			if err := addName(readName()); err != nil {
+				return nil, err
+			}
+		case s[0] == '[':
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("незакрытая скобка в селекторе %q", spec)
+			}
+			inner := strings.TrimSpace(s[1:end])
+			s = s[end+1:]
+
+			switch {
+			case inner == "*":
+				sel = append(sel, selectorToken{kind: selAnyIndex})
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				sel = append(sel, selectorToken{kind: selKey, key: inner[1 : len(inner)-1]})
+			default:
+				index, err := strconv.Atoi(inner)
+				if err != nil || index < 0 {
+					return nil, fmt.Errorf("некорректный индекс %q в селекторе %q", inner, spec)
+				}
+				sel = append(sel, selectorToken{kind: selIndex, index: index})
+			}
+		default:
+			return nil, fmt.Errorf("не удалось разобрать селектор %q", spec)
+		}
+	}
+
+	if len(sel) == 0 {
+		return nil, fmt.Errorf("пустой селектор %q", spec)
+	}
+	return sel, nil
+}
+
+// match - совпадает ли начало пути с селектором
+func (sel jsonSelector) match(path []jsonPathElem) bool {
+	return sel.matchFrom(0, path)
+}
+
+func (sel jsonSelector) matchFrom(ti int, path []jsonPathElem) bool {
+	if ti == len(sel) {
+		return true
+	}
+	token := sel[ti]
+	if token.kind == selRecursive {
+		for skip := 0; skip <= len(path); skip++ {
+			if sel.matchFrom(ti+1, path[skip:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(path) == 0 {
+		return false
+	}
+
+	elem := path[0]
+	isIndex := elem.index >= 0
+	switch token.kind {
+	case selKey:
+		if isIndex || elem.key != token.key {
+			return false
+		}
+	case selAnyKey:
+		// "*" - любое поле объекта; элементы массива выбираются через [*]
+		if isIndex {
+			return false
+		}
+	case selIndex:
+		if !isIndex || elem.index != token.index {
+			return false
+		}
+	case selAnyIndex:
+		if !isIndex {
+			return false
+		}
+	}
+	return sel.matchFrom(ti+1, path[1:])
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONParser(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     func(*FormatOptions)
+		input    string
+		expected string
+	}{
+		{
+			name:     "экранированный слеш и порядок ключей",
+			input:    `{"z":1,"url":"https:\/\/a.io\/x","a":"текст"}`,
+			expected: `{"z":1,"url":"https://******","a":"текст"}`,
+		},
+		{
+			name:     "строка без ссылок остается как была",
+			input:    `{"msg":"привет \/ ok"}`,
+			expected: `{"msg":"привет \/ ok"}`,
+		},
+		{
+			name:     "форматирование и вложенность сохраняются",
+			input:    "{\n  \"items\": [ {\"link\": \"http://a.b/<c>\"}, \"\", null ],\n  \"n\": 1.5e3\n}",
+			expected: "{\n  \"items\": [ {\"link\": \"http://*******\"}, \"\", null ],\n  \"n\": 1.5e3\n}",
+		},
+		{
+			name:     "селектор include",
+			opts:     func(o *FormatOptions) { o.JSONInclude = []string{"$.req.referer"} },
+			input:    `{"url":"http://a.io","req":{"referer":"http://b.io"}}`,
+			expected: `{"url":"http://a.io","req":{"referer":"http://****"}}`,
+		},
+		{
+			name:     "селектор exclude с массивом",
+			opts:     func(o *FormatOptions) { o.JSONExclude = []string{"links[0]", "$..public"} },
+			input:    `{"links":["http://a.io","http://b.io"],"x":{"public":"http://c.io"}}`,
+			expected: `{"links":["http://a.io","http://****"],"x":{"public":"http://c.io"}}`,
+		},
+		{
+			name:     "маска без экранирования HTML",
+			input:    `["see http://a.io/?x=1&y=2 <b>"]`,
+			expected: `["see http://************* <b>"]`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opts := DefaultFormatOptions()
+			if test.opts != nil {
+				test.opts(&opts)
+			}
+			result := maskDocument(t, newJSONParser(opts, false), test.input)
+			assert.Equal(t, test.expected, result)
+			assert.True(t, json.Valid([]byte(result)))
+		})
+	}
+}
+
+func TestJSONLinesParser_Malformed(t *testing.T) {
+	input := "{\"u\":\"http://a.io\"}\n\nне json http://b.io\n{\"u\":\"http://c.io\"}\n"
+
+	tests := []struct {
+		policy   string
+		expected string
+	}{
+		{MalformedText, "{\"u\":\"http://****\"}\n\nне json http://****\n{\"u\":\"http://****\"}\n"},
+		{MalformedKeep, "{\"u\":\"http://****\"}\n\nне json http://b.io\n{\"u\":\"http://****\"}\n"},
+		{MalformedSkip, "{\"u\":\"http://****\"}\n\n{\"u\":\"http://****\"}\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.policy, func(t *testing.T) {
+			opts := DefaultFormatOptions()
+			opts.JSONMalformed = test.policy
+			assert.Equal(t, test.expected, maskDocument(t, newJSONParser(opts, true), input))
+		})
+	}
+
+	t.Run(MalformedFail, func(t *testing.T) {
+		opts := DefaultFormatOptions()
+		opts.JSONMalformed = MalformedFail
+		_, err := newJSONParser(opts, true)([]byte(input))
+		assert.ErrorContains(t, err, "строке 3")
+	})
+}
+
+func TestParseJSONSelector(t *testing.T) {
+	valid := []string{"$.a.b", "a.b", "items[*].url", "headers.*", "$..url", "$['a.b'][2]"}
+	for _, spec := range valid {
+		_, err := parseJSONSelector(spec)
+		require.NoError(t, err, spec)
+	}
+
+	invalid := []string{"$", "a..", "items[x]", "a[1", "a.[1]"}
+	for _, spec := range invalid {
+		_, err := parseJSONSelector(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestJSONSelector_AnyKey(t *testing.T) {
+	sel, err := parseJSONSelector("a.*.b")
+	require.NoError(t, err)
+
+	assert.True(t, sel.match([]jsonPathElem{{key: "a", index: -1}, {key: "x", index: -1}, {key: "b", index: -1}}))
+	assert.False(t, sel.match([]jsonPathElem{{key: "a", index: -1}, {index: 0}, {key: "b", index: -1}}),
+		"* выбирает только поля объекта, а не элементы массива")
+
+	opts := DefaultFormatOptions()
+	opts.JSONInclude = []string{"a.*.b"}
+	parse := newJSONParser(opts, false)
+	assert.Equal(t, `{"a":{"x":{"b":"http://****"}}}`, maskDocument(t, parse, `{"a":{"x":{"b":"http://x.io"}}}`))
+	assert.Equal(t, `{"a":[{"b":"http://l.io"}]}`, maskDocument(t, parse, `{"a":[{"b":"http://l.io"}]}`))
+}
@@ -0,0 +1,329 @@
+package service
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Политики для блоков и фрагментов кода в Markdown
+const (
+	CodePolicyMask = "mask"
+	CodePolicyKeep = "keep"
+)
+
+var (
+	mdReferencePattern = regexp.MustCompile(`^( {0,3}\[[^\]]+\]:[ \t]*)(<[^<>\n]*>|\S+)(.*)$`)
+	mdAutolinkPattern  = regexp.MustCompile(`^<[A-Za-z][A-Za-z0-9+.\-]{1,31}:[^\s<>]*>`)
+)
+
+// mdParser разбирает Markdown построчно: ограждённые блоки кода, определения
+// ссылок вида [1]: https://... и внутристрочная разметка (ссылки, картинки,
+// автоссылки, фрагменты кода). На маскировку уходят адреса ссылок и обычный текст,
+// синтаксис разметки сохраняется как есть.
+type mdParser struct {
+	opts FormatOptions
+	doc  *document
+}
+
+func newMarkdownParser(opts FormatOptions) documentParser {
+	return func(data []byte) (*document, error) {
+		p := &mdParser{opts: opts, doc: &document{}}
+		p.parse(string(data))
+		return p.doc, nil
+	}
+}
+
+func (p *mdParser) parse(text string) {
+	fence := ""
+	for _, raw := range splitLinesKeepEnds(text) {
+		line, eol := cutEOL(raw)
+
+		switch {
+		case fence != "":
+			if isClosingFence(line, fence) {
+				p.doc.addText(line)
+				fence = ""
+			} else {
+				p.code(line)
+			}
+		case openingFence(line) != "":
+			fence = openingFence(line)
+			p.doc.addText(line)
+		case mdReferencePattern.MatchString(line):
+			p.reference(line)
+		default:
+			p.inline(line, false)
+		}
+
+		p.doc.addText(eol)
+	}
+}
+
+// reference - определение ссылки "[label]: destination "title""
+func (p *mdParser) reference(line string) {
+	groups := mdReferencePattern.FindStringSubmatch(line)
+	p.doc.addText(groups[1])
+	p.destination(groups[2])
+	p.doc.addSegment(groups[3])
+}
+
+func (p *mdParser) destination(dest string) {
+	if strings.HasPrefix(dest, "<") && strings.HasSuffix(dest, ">") {
+		p.doc.addText("<")
+		p.doc.addSegment(dest[1 : len(dest)-1])
+		p.doc.addText(">")
+		return
+	}
+	p.doc.addSegment(dest)
+}
+
+func (p *mdParser) code(text string) {
+	if p.opts.MarkdownCode == CodePolicyKeep {
+		p.doc.addText(text)
+		return
+	}
+	p.doc.addSegment(text)
+}
+
+// inline разбирает внутристрочную разметку. inLinkText - разбирается текст ссылки,
+// он маскируется только при включенной опции MarkdownLinkText.
+func (p *mdParser) inline(s string, inLinkText bool) {
+	textStart := 0
+	flush := func(end int) {
+		if inLinkText && !p.opts.MarkdownLinkText {
+			p.doc.addText(s[textStart:end])
+		} else {
+			p.doc.addSegment(s[textStart:end])
+		}
+	}
+
+	for i := 0; i < len(s); {
+		switch s[i] {
+		case '\\':
+			i += 2
+		case '`':
+			run := countRun(s, i, '`')
+			closing := findBacktickRun(s, i+run, run)
+			if closing < 0 {
+				i += run
+				continue
+			}
+			flush(i)
+			p.doc.addText(s[i : i+run])
+			p.code(s[i+run : closing])
+			p.doc.addText(s[closing : closing+run])
+			i = closing + run
+			textStart = i
+		case '<':
+			loc := mdAutolinkPattern.FindStringIndex(s[i:])
+			if loc == nil {
+				i++
+				continue
+			}
+			flush(i)
+			p.doc.addText("<")
+			p.doc.addSegment(s[i+1 : i+loc[1]-1])
+			p.doc.addText(">")
+			i += loc[1]
+			textStart = i
+		case '[':
+			end, ok := p.inlineLink(s, i, flush)
+			if !ok {
+				i++
+				continue
+			}
+			i = end
+			textStart = i
+		default:
+			i++
+		}
+	}
+	flush(len(s))
+}
+
+// inlineLink разбирает ссылку или картинку "[text](destination "title")", начинающуюся с s[open] == '['.
+// Возвращает позицию после закрывающей скобки.
+func (p *mdParser) inlineLink(s string, open int, flush func(int)) (int, bool) {
+	closeText := findClosingBracket(s, open)
+	if closeText < 0 || closeText+1 >= len(s) || s[closeText+1] != '(' {
+		return 0, false
+	}
+
+	i := closeText + 2
+	for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+		i++
+	}
+
+	destStart := i
+	destEnd, ok := scanLinkDestination(s, i)
+	if !ok {
+		return 0, false
+	}
+	i = destEnd
+
+	titleStart := i
+	for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+		i++
+	}
+	if i < len(s) && (s[i] == '"' || s[i] == '\'' || s[i] == '(') {
+		closer := s[i]
+		if closer == '(' {
+			closer = ')'
+		}
+		end := findUnescaped(s, i+1, closer)
+		if end < 0 {
+			return 0, false
+		}
+		i = end + 1
+		for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+			i++
+		}
+	}
+	if i >= len(s) || s[i] != ')' {
+		return 0, false
+	}
+
+	flush(open)
+	p.doc.addText("[")
+	p.inline(s[open+1:closeText], true)
+	p.doc.addText(s[closeText:destStart])
+	p.destination(s[destStart:destEnd])
+	p.doc.addSegment(s[titleStart:i])
+	p.doc.addText(")")
+	return i + 1, true
+}
+
+// scanLinkDestination - адрес в угловых скобках или без пробелов со сбалансированными скобками
+func scanLinkDestination(s string, i int) (int, bool) {
+	if i < len(s) && s[i] == '<' {
+		end := findUnescaped(s, i+1, '>')
+		if end < 0 {
+			return 0, false
+		}
+		return end + 1, true
+	}
+
+	depth := 0
+	for ; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				return i, true
+			}
+			depth--
+		case ' ', '\t':
+			return i, depth == 0
+		}
+	}
+	return i, depth == 0
+}
+
+func findClosingBracket(s string, open int) int {
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '`':
+			run := countRun(s, i, '`')
+			if closing := findBacktickRun(s, i+run, run); closing >= 0 {
+				i = closing + run - 1
+			} else {
+				i += run - 1
+			}
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func findUnescaped(s string, from int, target byte) int {
+	for i := from; i < len(s); i++ {
+		if s[i] == '\\' {
+			i++
+			continue
+		}
+		if s[i] == target {
+			return i
+		}
+	}
+	return -1
+}
+
+func countRun(s string, from int, ch byte) int {
+	n := 0
+	for from+n < len(s) && s[from+n] == ch {
+		n++
+	}
+	return n
+}
+
+// findBacktickRun ищет закрывающую последовательность ровно из n обратных кавычек
+func findBacktickRun(s string, from, n int) int {
+	for i := from; i < len(s); {
+		if s[i] != '`' {
+			i++
+			continue
+		}
+		run := countRun(s, i, '`')
+		if run == n {
+			return i
+		}
+		i += run
+	}
+	return -1
+}
+
+// openingFence возвращает маркер ограждённого блока кода (``` или ~~~) или пустую строку
+func openingFence(line string) string {
+	trimmed := strings.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 || trimmed == "" {
+		return ""
+	}
+	ch := trimmed[0]
+	if ch != '`' && ch != '~' {
+		return ""
+	}
+	run := countRun(trimmed, 0, ch)
+	if run < 3 {
+		return ""
+	}
+	if ch == '`' && strings.Contains(trimmed[run:], "`") {
+		return ""
+	}
+	return trimmed[:run]
+}
+
+func isClosingFence(line, fence string) bool {
+	trimmed := strings.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 {
+		return false
+	}
+	run := countRun(trimmed, 0, fence[0])
+	return run >= len(fence) && strings.TrimSpace(trimmed[run:]) == ""
+}
+
+// splitLinesKeepEnds делит текст на строки, сохраняя переводы строк
+func splitLinesKeepEnds(text string) []string {
+	return strings.SplitAfter(text, "\n")
+}
+
+func cutEOL(line string) (string, string) {
+	if strings.HasSuffix(line, "\r\n") {
+		return line[:len(line)-2], "\r\n"
+	}
+	if strings.HasSuffix(line, "\n") {
+		return line[:len(line)-1], "\n"
+	}
+	return line, ""
+}
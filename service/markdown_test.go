@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// maskDocument - разбор, маскировка сегментов и обратная сборка без воркеров
+func maskDocument(t *testing.T, parse documentParser, input string) string {
+	t.Helper()
+
+	doc, err := parse([]byte(input))
+	require.NoError(t, err)
+
+	var masked []string
+	for _, segment := range doc.Segments() {
+		masked = append(masked, maskLink(segment))
+	}
+
+	out, err := doc.Render(masked)
+	require.NoError(t, err)
+	return string(out)
+}
+
+func TestMarkdownParser(t *testing.T) {
+	parse := newMarkdownParser(DefaultFormatOptions())
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "скобка после адреса сохраняется",
+			input:    "см. [docs](https://x/y) и дальше",
+			expected: "см. [docs](https://***) и дальше",
+		},
+		{
+			name:     "заголовок ссылки",
+			input:    `[a](http://site.ru/p "подробнее")`,
+			expected: `[a](http://********* "подробнее")`,
+		},
+		{
+			name:     "картинка внутри ссылки",
+			input:    "[![logo](https://cdn.io/l.png)](https://site.io)",
+			expected: "[![logo](https://************)](https://*******)",
+		},
+		{
+			name:     "определение ссылки",
+			input:    "[1]: https://example.com/a \"title\"\n",
+			expected: "[1]: https://************* \"title\"\n",
+		},
+		{
+			name:     "автоссылка",
+			input:    "пишите <https://support.io/x>.",
+			expected: "пишите <https://************>.",
+		},
+		{
+			name:     "адрес со скобками",
+			input:    "[wiki](https://w.org/A_(b))",
+			expected: "[wiki](https://***********)",
+		},
+		{
+			name:     "голая ссылка в тексте",
+			input:    "текст http://a.ru\r\nвторая строка\r\n",
+			expected: "текст http://****\r\nвторая строка\r\n",
+		},
+		{
+			name:     "текст ссылки не трогается по умолчанию",
+			input:    "[https://a.io](https://a.io)",
+			expected: "[https://a.io](https://****)",
+		},
+		{
+			name:     "не ссылка",
+			input:    "массив [1] и (скобки) https://x",
+			expected: "массив [1] и (скобки) https://*",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, maskDocument(t, parse, test.input))
+		})
+	}
+}
+
+func TestMarkdownParser_Options(t *testing.T) {
+	input := "```sh\ncurl https://api.io/v1\n```\nвызов `get https://api.io` и [https://a.io](https://a.io)\n"
+
+	t.Run("код маскируется по умолчанию", func(t *testing.T) {
+		parse := newMarkdownParser(DefaultFormatOptions())
+		expected := "```sh\ncurl https://*********\n```\nвызов `get https://******` и [https://a.io](https://****)\n"
+		assert.Equal(t, expected, maskDocument(t, parse, input))
+	})
+
+	t.Run("код сохраняется, текст ссылки маскируется", func(t *testing.T) {
+		opts := DefaultFormatOptions()
+		opts.MarkdownCode = CodePolicyKeep
+		opts.MarkdownLinkText = true
+		parse := newMarkdownParser(opts)
+		expected := "```sh\ncurl https://api.io/v1\n```\nвызов `get https://api.io` и [https://****](https://****)\n"
+		assert.Equal(t, expected, maskDocument(t, parse, input))
+	})
+
+	t.Run("незакрытый блок кода", func(t *testing.T) {
+		opts := DefaultFormatOptions()
+		opts.MarkdownCode = CodePolicyKeep
+		parse := newMarkdownParser(opts)
+		input := "~~~\nhttps://a.io\n"
+		assert.Equal(t, input, maskDocument(t, parse, input))
+	})
+}
+
+func TestServiceFactory_Markdown(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "README.md")
+	output := filepath.Join(dir, "masked.md")
+	content := "# Заголовок\n\n\n  [docs](https://x/y)\n  - пункт http://a.b\n"
+	require.NoError(t, os.WriteFile(input, []byte(content), 0644))
+
+	factory := NewServiceFactory(3, false)
+	svc := factory.CreateMaskService(input, output)
+	require.NoError(t, svc.Run(context.Background()))
+
+	result, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Equal(t, "# Заголовок\n\n\n  [docs](https://***)\n  - пункт http://***\n", string(result))
+}
+
+func TestParseFormat(t *testing.T) {
+	format, err := ParseFormat("MD")
+	require.NoError(t, err)
+	assert.Equal(t, FormatMarkdown, format)
+
+	_, err = ParseFormat("pdf")
+	assert.Error(t, err)
+
+	assert.Equal(t, FormatMarkdown, DetectFormat("docs/README.markdown"))
+	assert.Equal(t, FormatText, DetectFormat("logs.txt"))
+
+	opts := DefaultFormatOptions()
+	opts.MarkdownCode = "drop"
+	assert.Error(t, NewServiceFactory(1, false).SetFormat(FormatMarkdown, opts))
+}
@@ -37,6 +37,16 @@ func TestMaskLink(t *testing.T) {
 			input:    "Текст http://link",
 			expected: "Текст http://****",
 		},
+		{
+			name:     "ссылка до табуляции",
+			input:    "Текст http://link\tдальше",
+			expected: "Текст http://****\tдальше",
+		},
+		{
+			name:     "ссылка до неразрывного пробела",
+			input:    "Текст http://link\u00a0дальше",
+			expected: "Текст http://****\u00a0дальше",
+		},
 		{
 			name:     "пустая строка",
 			input:    "",
@@ -0,0 +1,33 @@
+package service
+
+// MemoryProducer отдает на маскировку строки, уже находящиеся в памяти
+// (например, тело HTTP-запроса)
+type MemoryProducer struct {
+	lines []string
+}
+
+func NewMemoryProducer(lines []string) *MemoryProducer {
+	return &MemoryProducer{lines: lines}
+}
+
+func (producer *MemoryProducer) Produce() ([]string, error) {
+	return producer.lines, nil
+}
+
+// MemoryPresenter сохраняет замаскированные строки в памяти без изменений
+type MemoryPresenter struct {
+	lines []string
+}
+
+func NewMemoryPresenter() *MemoryPresenter {
+	return &MemoryPresenter{}
+}
+
+func (presenter *MemoryPresenter) Present(lines []string) error {
+	presenter.lines = lines
+	return nil
+}
+
+func (presenter *MemoryPresenter) Lines() []string {
+	return presenter.lines
+}
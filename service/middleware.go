@@ -0,0 +1,305 @@
+package service
+
+import (
+	"bytes"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"LinkMaskirator/masking"
+)
+
+// MiddlewareConfig - настройки NewMaskingMiddleware
+type MiddlewareConfig struct {
+	// ContentTypes - типы ответов, которые маскируются. text/plain маскируется
+	// построчно по мере записи, HTML, JSON, JSONL и Markdown - целиком
+	ContentTypes []string
+	// Workers - воркеров на маскировку одного ответа
+	Workers int
+	// MaxBodySize - максимальный размер ответа, который разбирается целиком
+	MaxBodySize int64
+	// Options - настройки разбора HTML, JSON и Markdown
+	Options FormatOptions
+}
+
+func DefaultMiddlewareConfig() MiddlewareConfig {
+	return MiddlewareConfig{
+		ContentTypes: []string{"text/plain", "text/html", "application/json"},
+		Workers:      10,
+		MaxBodySize:  10 << 20,
+		Options:      DefaultFormatOptions(),
+	}
+}
+
+// NewMaskingMiddleware маскирует тела ответов next с типами из cfg.ContentTypes.
+// Сжатые обработчиком ответы передаются как есть. Если ответ для разбора
+// целиком больше MaxBodySize, клиент получает 500, а не немаскированные данные.
+func NewMaskingMiddleware(masker *masking.Masker, cfg MiddlewareConfig) func(http.Handler) http.Handler {
+	if masker == nil {
+		masker = defaultMasker
+	}
+	if cfg.MaxBodySize <= 0 {
+		cfg.MaxBodySize = DefaultMiddlewareConfig().MaxBodySize
+	}
+
+	types := make(map[string]bool, len(cfg.ContentTypes))
+	for _, contentType := range cfg.ContentTypes {
+		types[strings.ToLower(strings.TrimSpace(contentType))] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mw := &maskingResponseWriter{ResponseWriter: w, r: r, masker: masker, cfg: cfg, types: types}
+			next.ServeHTTP(mw, r)
+			mw.finish()
+		})
+	}
+}
+
+type responseMode int
+
+const (
+	modeUndecided responseMode = iota
+	modePass
+	modeStream
+	modeBuffer
+)
+
+// maskingResponseWriter откладывает заголовки до первой записи, чтобы по
+// Content-Type решить, как маскировать ответ
+type maskingResponseWriter struct {
+	http.ResponseWriter
+	r      *http.Request
+	masker *masking.Masker
+	cfg    MiddlewareConfig
+	types  map[string]bool
+
+	status   int
+	mode     responseMode
+	format   Format
+	stream   *masking.MaskingWriter
+	buf      bytes.Buffer
+	overflow bool
+}
+
+func (w *maskingResponseWriter) WriteHeader(status int) {
+	// Информационные ответы не несут тела и уходят сразу
+	if status >= 100 && status < 200 {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *maskingResponseWriter) Write(p []byte) (int, error) {
+	if w.mode == modeUndecided {
+		w.decide(p)
+	}
+
+	switch w.mode {
+	case modeStream:
+		return w.stream.Write(p)
+	case modeBuffer:
+		if !w.overflow {
+			if int64(w.buf.Len()+len(p)) > w.cfg.MaxBodySize {
+				w.overflow = true
+				w.buf = bytes.Buffer{}
+			} else {
+				w.buf.Write(p)
+			}
+		}
+		return len(p), nil
+	default:
+		return w.ResponseWriter.Write(p)
+	}
+}
+
+// Flush отправляет уже замаскированную часть ответа. Незавершенная строка
+// text/plain и ответы, которые разбираются целиком, ждут конца обработчика.
+func (w *maskingResponseWriter) Flush() {
+	if w.mode == modeUndecided || w.mode == modeBuffer {
+		return
+	}
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *maskingResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *maskingResponseWriter) decide(p []byte) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	header := w.Header()
+	if header.Get("Content-Type") == "" && len(p) > 0 {
+		// Так же поступил бы net/http, но уже после нашего решения
+		header.Set("Content-Type", http.DetectContentType(p))
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	format, stream := proxyFormat(mediaType)
+	encoding := strings.ToLower(header.Get("Content-Encoding"))
+
+	switch {
+	case format == "" || !w.types[mediaType],
+		encoding != "" && encoding != "identity",
+		w.r.Method == http.MethodHead,
+		w.status == http.StatusNoContent || w.status == http.StatusNotModified:
+		w.mode = modePass
+		w.ResponseWriter.WriteHeader(w.status)
+		return
+	}
+
+	header.Del("Content-Length")
+	header.Del("Etag")
+	header.Del("Content-Md5")
+	if stream {
+		w.mode = modeStream
+		w.ResponseWriter.WriteHeader(w.status)
+		w.stream = masking.NewMaskingWriter(w.ResponseWriter, masking.WithMasker(w.masker))
+		return
+	}
+	w.mode = modeBuffer
+	w.format = format
+}
+
+// finish дописывает остаток ответа после возврата из обработчика
+func (w *maskingResponseWriter) finish() {
+	if w.mode == modeUndecided {
+		if w.status == 0 {
+			// Обработчик ничего не записал - ответ сформирует net/http
+			return
+		}
+		w.decide(nil)
+	}
+
+	switch w.mode {
+	case modeStream:
+		if err := w.stream.Close(); err != nil {
+			slog.DebugContext(w.r.Context(), "ошибка отправки ответа", "error", err)
+		}
+	case modeBuffer:
+		w.finishBuffer()
+	}
+}
+
+func (w *maskingResponseWriter) finishBuffer() {
+	ctx := w.r.Context()
+	if w.overflow {
+		slog.ErrorContext(ctx, "ответ слишком большой для маскировки",
+			"url", w.r.URL.String(), "max-body-size", w.cfg.MaxBodySize)
+		http.Error(w.ResponseWriter, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	masked, err := maskHTTPBody(ctx, w.masker, w.cfg.Workers, w.cfg.Options, w.format, w.buf.Bytes())
+	if err != nil {
+		slog.ErrorContext(ctx, "ошибка маскировки ответа", "url", w.r.URL.String(), "error", err)
+		http.Error(w.ResponseWriter, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Length", strconv.Itoa(len(masked)))
+	w.ResponseWriter.WriteHeader(w.status)
+	if _, err := w.ResponseWriter.Write(masked); err != nil {
+		slog.DebugContext(ctx, "ошибка отправки ответа", "error", err)
+	}
+}
+
+// NewAccessLogMiddleware пишет строку access-лога на каждый запрос. Ссылки
+// в пути и Referer маскируются masker, значения параметров запроса
+// заменяются на '*' целиком - в них часто передают токены и адреса.
+func NewAccessLogMiddleware(logger *slog.Logger, masker *masking.Masker) func(http.Handler) http.Handler {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if masker == nil {
+		masker = defaultMasker
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := &statusRecorder{ResponseWriter: w}
+			next.ServeHTTP(rec, r)
+			if rec.status == 0 {
+				rec.status = http.StatusOK
+			}
+
+			logger.InfoContext(r.Context(), "запрос",
+				"method", r.Method,
+				"uri", MaskRequestURI(masker, r.RequestURI),
+				"referer", masker.Mask(r.Referer()),
+				"status", rec.status,
+				"size", rec.size,
+				"duration", time.Since(start),
+				"remote", r.RemoteAddr,
+			)
+		})
+	}
+}
+
+// MaskRequestURI маскирует путь запроса правилами masker, а значения
+// параметров после '?' - полностью: "/a?token=abc&x" -> "/a?token=***&x"
+func MaskRequestURI(masker *masking.Masker, uri string) string {
+	path, query, found := strings.Cut(uri, "?")
+	path = masker.Mask(path)
+	if !found {
+		return path
+	}
+
+	var b strings.Builder
+	b.Grow(len(uri))
+	b.WriteString(path)
+	b.WriteByte('?')
+	for i, param := range strings.Split(query, "&") {
+		if i > 0 {
+			b.WriteByte('&')
+		}
+		key, value, hasValue := strings.Cut(param, "=")
+		b.WriteString(key)
+		if hasValue {
+			b.WriteByte('=')
+			b.WriteString(strings.Repeat("*", utf8.RuneCountInString(value)))
+		}
+	}
+	return b.String()
+}
+
+// statusRecorder запоминает код и размер ответа для access-лога
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 && status >= 200 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(p []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(p)
+	r.size += n
+	return n, err
+}
+
+func (r *statusRecorder) Flush() {
+	http.NewResponseController(r.ResponseWriter).Flush()
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"LinkMaskirator/masking"
+)
+
+func serveMiddleware(t *testing.T, cfg MiddlewareConfig, handler http.HandlerFunc) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	NewMaskingMiddleware(nil, cfg)(handler).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	return rec
+}
+
+func TestMaskingMiddleware(t *testing.T) {
+	cfg := DefaultMiddlewareConfig()
+
+	t.Run("HTML целиком", func(t *testing.T) {
+		body := `<p>см. <a href="https://a.io/x">https://a.io/x</a></p>`
+		rec := serveMiddleware(t, cfg, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			w.WriteHeader(http.StatusCreated)
+			io.WriteString(w, body[:10])
+			io.WriteString(w, body[10:])
+		})
+
+		masked := `<p>см. <a href="https://******">https://******</a></p>`
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Equal(t, masked, rec.Body.String())
+		assert.Equal(t, strconv.Itoa(len(masked)), rec.Header().Get("Content-Length"))
+	})
+
+	t.Run("текст без Content-Type", func(t *testing.T) {
+		rec := serveMiddleware(t, cfg, func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, "one http://a.io/")
+			w.(http.Flusher).Flush()
+			io.WriteString(w, "long\ntwo")
+		})
+		assert.Equal(t, "one http://*********\ntwo", rec.Body.String())
+		assert.True(t, strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain"))
+	})
+
+	t.Run("тип не из списка", func(t *testing.T) {
+		rec := serveMiddleware(t, MiddlewareConfig{ContentTypes: []string{"text/html"}}, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"url": "http://a.io"}`)
+		})
+		assert.Equal(t, `{"url": "http://a.io"}`, rec.Body.String())
+	})
+
+	t.Run("сжатый ответ", func(t *testing.T) {
+		rec := serveMiddleware(t, cfg, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain")
+			w.Header().Set("Content-Encoding", "br")
+			io.WriteString(w, "http://a.io")
+		})
+		assert.Equal(t, "http://a.io", rec.Body.String())
+	})
+
+	t.Run("слишком большой ответ", func(t *testing.T) {
+		small := cfg
+		small.MaxBodySize = 8
+		rec := serveMiddleware(t, small, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"url": "http://a.io"}`)
+		})
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.NotContains(t, rec.Body.String(), "a.io")
+	})
+}
+
+func TestAccessLogMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	handler := NewAccessLogMiddleware(logger, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+		io.WriteString(w, "ok")
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/go?to=https%3A%2F%2Fb.io&token=abc&flag", nil)
+	req.Header.Set("Referer", "https://c.io/secret")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	line := buf.String()
+	assert.Contains(t, line, `uri="/go?to=******************&token=***&flag"`)
+	assert.Contains(t, line, "referer=https://***********")
+	assert.Contains(t, line, "status=418")
+	assert.Contains(t, line, "size=2")
+	assert.NotContains(t, line, "b.io")
+	assert.NotContains(t, line, "c.io")
+}
+
+func TestMaskRequestURI(t *testing.T) {
+	masker := masking.NewLinkMasker()
+	assert.Equal(t, "/a/b", MaskRequestURI(masker, "/a/b"))
+	assert.Equal(t, "http://******", MaskRequestURI(masker, "http://a.io/b"))
+	require.Equal(t, "/a?x=*&y=&z", MaskRequestURI(masker, "/a?x=1&y=&z"))
+}
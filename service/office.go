@@ -0,0 +1,335 @@
+package service
+
+import (
+	"html"
+	"path"
+	"strings"
+	"unicode/utf8"
+)
+
+// formatOfficeXML - XML-часть документа Office в отчете по архиву
+const formatOfficeXML Format = "xml"
+
+// Атрибуты частей Office, значение которых может быть адресом: цель связи
+// в *.rels (только для TargetMode="External"), подсказка и отображаемый
+// текст гиперссылки в Excel
+var officeURLAttributes = map[string]bool{
+	"Target":  true,
+	"display": true,
+	"tooltip": true,
+}
+
+// NewOfficePair - пара для DOCX/XLSX/PPTX. Документ Office - zip с XML-частями:
+// маскируются текст частей (абзацы, ячейки, общие строки, слайды) и внешние
+// ссылки в связях *.rels. Остальное содержимое (картинки, макросы, стили)
+// переносится без изменений, чтобы документ открывался как прежде.
+func NewOfficePair(inputPath, outputPath string, options FormatOptions) (*ArchiveProducer, *ArchivePresenter) {
+	producer, presenter := NewArchivePair(inputPath, outputPath, options)
+	producer.office = true
+	return producer, presenter
+}
+
+// isOfficeXMLPart - часть документа, в которой могут быть ссылки.
+// [Content_Types].xml описывает типы частей и не меняется.
+func isOfficeXMLPart(name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".xml", ".rels":
+		return path.Base(name) != "[Content_Types].xml"
+	default:
+		return false
+	}
+}
+
+// Элементы-абзацы (Word, PowerPoint, строки Excel) и элементы с текстом фрагмента.
+// Word и PowerPoint режут текст абзаца на фрагменты (<w:r><w:t>) по форматированию
+// и правкам, поэтому ссылка может оказаться разрезанной между ними.
+var (
+	officeParagraphElements = map[string]bool{"p": true, "si": true, "is": true}
+	officeTextElements      = map[string]bool{"t": true}
+)
+
+// xmlParser - токенизатор XML, сохраняющий исходные байты разметки. Текст
+// и выбранные атрибуты маскируются после раскрытия сущностей (&amp; и т.п.),
+// измененные значения экранируются обратно.
+type xmlParser struct {
+	doc *document
+	src string
+
+	paragraphs []int          // индексы doc.parts, с которых начинаются открытые абзацы
+	inText     bool           // внутри элемента текста фрагмента
+	runs       map[int]string // части с текстом фрагментов: индекс в doc.parts -> исходный текст
+}
+
+func parseOfficeXML(data []byte) (*document, error) {
+	p := &xmlParser{doc: &document{}, src: string(data), runs: make(map[int]string)}
+	p.parse()
+	return p.doc, nil
+}
+
+func (p *xmlParser) parse() {
+	s := p.src
+	textStart := 0
+	for i := 0; i < len(s); {
+		if s[i] != '<' {
+			i++
+			continue
+		}
+
+		p.text(s[textStart:i])
+		var end int
+		switch {
+		case strings.HasPrefix(s[i:], "<!--"):
+			end = indexFrom(s, i, "-->")
+			p.doc.addText(s[i:end])
+		case strings.HasPrefix(s[i:], "<![CDATA["):
+			end = p.cdata(i)
+		case strings.HasPrefix(s[i:], "</"):
+			end = indexFrom(s, i, ">")
+			p.endElement(xmlLocalName(s[i+2:]))
+			p.doc.addText(s[i:end])
+		case strings.HasPrefix(s[i:], "<?"), strings.HasPrefix(s[i:], "<!"):
+			end = indexFrom(s, i, ">")
+			p.doc.addText(s[i:end])
+		default:
+			end = p.startTag(i)
+			if s[end-2] != '/' {
+				p.startElement(xmlLocalName(s[i+1:]))
+			}
+		}
+		i, textStart = end, end
+	}
+	p.text(s[textStart:])
+}
+
+// text - текст между тегами; пробельные промежутки между элементами не маскируются
+func (p *xmlParser) text(raw string) {
+	if p.inText && raw != "" {
+		p.runs[len(p.doc.parts)] = raw
+	} else if strings.TrimSpace(raw) == "" {
+		p.doc.addText(raw)
+		return
+	}
+	p.doc.addEncodedSegment(html.UnescapeString(raw), func(masked, original string) string {
+		if masked == original {
+			return raw
+		}
+		return escapeXML(masked, false)
+	})
+}
+
+func (p *xmlParser) startElement(name string) {
+	switch {
+	case officeParagraphElements[name]:
+		p.paragraphs = append(p.paragraphs, len(p.doc.parts))
+	case officeTextElements[name]:
+		p.inText = true
+	}
+}
+
+func (p *xmlParser) endElement(name string) {
+	switch {
+	case officeParagraphElements[name] && len(p.paragraphs) > 0:
+		from := p.paragraphs[len(p.paragraphs)-1]
+		p.paragraphs = p.paragraphs[:len(p.paragraphs)-1]
+		p.joinRuns(from)
+	case officeTextElements[name]:
+		p.inText = false
+	}
+}
+
+// joinRuns склеивает текст фрагментов абзаца, начинающегося с doc.parts[from], в один
+// сегмент, чтобы разрезанная ссылка маскировалась целиком. Результат раскладывается
+// обратно по фрагментам, разметка между ними не меняется. Если в абзаце маскируется
+// что-то кроме текста фрагментов (атрибуты, CDATA), фрагменты остаются отдельными.
+func (p *xmlParser) joinRuns(from int) {
+	parts := p.doc.parts[from:]
+	var raws, texts []string
+	for i, part := range parts {
+		if !part.maskable {
+			continue
+		}
+		raw, ok := p.runs[from+i]
+		if !ok {
+			return
+		}
+		raws = append(raws, raw)
+		texts = append(texts, part.text)
+	}
+	for i := range parts {
+		delete(p.runs, from+i)
+	}
+	if len(texts) < 2 {
+		return
+	}
+
+	layout := append([]docPart(nil), parts...)
+	joined := docPart{text: strings.Join(texts, ""), maskable: true, encode: func(masked, original string) string {
+		var b strings.Builder
+		pieces := splitRuns(masked, texts)
+		run := 0
+		for _, part := range layout {
+			if !part.maskable {
+				b.WriteString(part.text)
+				continue
+			}
+			if pieces[run] == texts[run] {
+				b.WriteString(raws[run])
+			} else {
+				b.WriteString(escapeXML(pieces[run], false))
+			}
+			run++
+		}
+		return b.String()
+	}}
+
+	p.doc.parts = append(p.doc.parts[:from], joined)
+	p.doc.segments -= len(texts) - 1
+}
+
+// splitRuns раскладывает замаскированный текст абзаца по исходным фрагментам runs.
+// Если длина в символах не изменилась, символы раскладываются один в один. Иначе
+// общие начало и конец остаются на своих местах, а измененная середина целиком
+// уходит во фрагмент, в котором она начиналась.
+func splitRuns(masked string, runs []string) []string {
+	original := []rune(strings.Join(runs, ""))
+	result := []rune(masked)
+	pieces := make([]string, len(runs))
+
+	if len(original) == len(result) {
+		pos := 0
+		for i, run := range runs {
+			n := utf8.RuneCountInString(run)
+			pieces[i] = string(result[pos : pos+n])
+			pos += n
+		}
+		return pieces
+	}
+
+	prefix := 0
+	for prefix < len(original) && prefix < len(result) && original[prefix] == result[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(original)-prefix && suffix < len(result)-prefix &&
+		original[len(original)-1-suffix] == result[len(result)-1-suffix] {
+		suffix++
+	}
+	changeEnd := len(original) - suffix
+
+	pos := 0
+	for i, run := range runs {
+		start, end := pos, pos+utf8.RuneCountInString(run)
+		var b strings.Builder
+		b.WriteString(string(original[start:max(start, min(end, prefix))]))
+		if prefix >= start && (prefix < end || i == len(runs)-1) {
+			b.WriteString(string(result[prefix : len(result)-suffix]))
+		}
+		b.WriteString(string(original[min(end, max(start, changeEnd)):end]))
+		pieces[i] = b.String()
+		pos = end
+	}
+	return pieces
+}
+
+// xmlLocalName - имя элемента без пространства имен: "w:t ..." -> "t"
+func xmlLocalName(tag string) string {
+	end := strings.IndexAny(tag, " \t\r\n/>")
+	if end < 0 {
+		end = len(tag)
+	}
+	name := tag[:end]
+	return name[strings.IndexByte(name, ':')+1:]
+}
+
+// cdata - "<![CDATA[ ... ]]>", содержимое маскируется как есть
+func (p *xmlParser) cdata(start int) int {
+	s := p.src
+	contentStart := start + len("<![CDATA[")
+	closing := strings.Index(s[contentStart:], "]]>")
+	if closing < 0 {
+		p.doc.addText(s[start:])
+		return len(s)
+	}
+	contentEnd := contentStart + closing
+
+	p.doc.addText(s[start:contentStart])
+	p.doc.addEncodedSegment(s[contentStart:contentEnd], func(masked, original string) string {
+		return strings.ReplaceAll(masked, "]]>", "]]]]><![CDATA[>")
+	})
+	p.doc.addText("]]>")
+	return contentEnd + 3
+}
+
+// startTag разбирает открывающий (или пустой) тег и маскирует значения атрибутов-адресов
+func (p *xmlParser) startTag(start int) int {
+	s := p.src
+	end := indexFrom(s, start, ">")
+
+	type attribute struct {
+		name       string
+		start, end int
+	}
+	var attrs []attribute
+	external := false
+	for i := start + 1; i < end; {
+		eq := strings.IndexByte(s[i:end], '=')
+		if eq < 0 {
+			break
+		}
+		name := strings.TrimSpace(s[i : i+eq])
+		if idx := strings.LastIndexAny(name, " \t\r\n"); idx >= 0 {
+			name = name[idx+1:]
+		}
+
+		quoteAt := i + eq + 1
+		for quoteAt < end && isHTMLSpace(s[quoteAt]) {
+			quoteAt++
+		}
+		if quoteAt >= end || (s[quoteAt] != '"' && s[quoteAt] != '\'') {
+			break
+		}
+		closing := strings.IndexByte(s[quoteAt+1:end], s[quoteAt])
+		if closing < 0 {
+			break
+		}
+		valueEnd := quoteAt + 1 + closing
+
+		attrs = append(attrs, attribute{name: name, start: quoteAt + 1, end: valueEnd})
+		if name == "TargetMode" && s[quoteAt+1:valueEnd] == "External" {
+			external = true
+		}
+		i = valueEnd + 1
+	}
+
+	literal := start
+	for _, attr := range attrs {
+		local := attr.name[strings.IndexByte(attr.name, ':')+1:]
+		if !officeURLAttributes[local] || (local == "Target" && !external) {
+			continue
+		}
+
+		raw := s[attr.start:attr.end]
+		p.doc.addText(s[literal:attr.start])
+		p.doc.addEncodedSegment(html.UnescapeString(raw), func(masked, original string) string {
+			if masked == original {
+				return raw
+			}
+			return escapeXML(masked, true)
+		})
+		literal = attr.end
+	}
+	p.doc.addText(s[literal:end])
+	return end
+}
+
+var (
+	xmlTextEscaper      = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	xmlAttributeEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;", "'", "&apos;")
+)
+
+func escapeXML(value string, attribute bool) string {
+	if attribute {
+		return xmlAttributeEscaper.Replace(value)
+	}
+	return xmlTextEscaper.Replace(value)
+}
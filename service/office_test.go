@@ -0,0 +1,154 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeZip(t *testing.T, path string, files [][2]string) {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, file := range files {
+		w, err := zw.Create(file[0])
+		require.NoError(t, err)
+		_, err = w.Write([]byte(file[1]))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0644))
+}
+
+func readZip(t *testing.T, path string) ([]string, map[string]string) {
+	t.Helper()
+	reader, err := zip.OpenReader(path)
+	require.NoError(t, err)
+	defer reader.Close()
+
+	var names []string
+	contents := make(map[string]string)
+	for _, file := range reader.File {
+		rc, err := file.Open()
+		require.NoError(t, err)
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		require.NoError(t, err)
+		names = append(names, file.Name)
+		contents[file.Name] = string(data)
+	}
+	return names, contents
+}
+
+func TestOffice_Docx(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "report.docx")
+	output := filepath.Join(dir, "masked.docx")
+
+	contentTypes := `<?xml version="1.0" encoding="UTF-8"?><Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"/>`
+	document := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n" +
+		`<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>` +
+		`<w:p><w:r><w:t xml:space="preserve">see https://a.io/?x=1&amp;y=2 </w:t></w:r></w:p>` +
+		`<w:p><w:hyperlink r:id="rId2"><w:r><w:t>click</w:t></w:r></w:hyperlink></w:p>` +
+		`</w:body></w:document>`
+	rels := `<?xml version="1.0" encoding="UTF-8"?>` +
+		`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/image" Target="media/image1.png"/>` +
+		`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/hyperlink" Target="https://b.io/a?p=1&amp;q=2" TargetMode="External"/>` +
+		`</Relationships>`
+
+	writeZip(t, input, [][2]string{
+		{"[Content_Types].xml", contentTypes},
+		{"word/document.xml", document},
+		{"word/_rels/document.xml.rels", rels},
+		{"word/media/image1.png", string(archiveBinary)},
+	})
+
+	factory := NewServiceFactory(2, false)
+	opts := DefaultFormatOptions()
+	opts.ArchiveBinary = BinaryDrop
+	require.NoError(t, factory.SetFormat(FormatAuto, opts))
+	svc := factory.CreateMaskService(input, output)
+	require.NoError(t, svc.Run(context.Background()))
+
+	names, contents := readZip(t, output)
+	assert.Equal(t, []string{"[Content_Types].xml", "word/document.xml", "word/_rels/document.xml.rels", "word/media/image1.png"}, names,
+		"порядок частей сохраняется, картинки не удаляются")
+	assert.Equal(t, contentTypes, contents["[Content_Types].xml"], "пространства имен не маскируются")
+	assert.Equal(t, string(archiveBinary), contents["word/media/image1.png"])
+
+	assert.Contains(t, contents["word/document.xml"], `<w:t xml:space="preserve">see https://************* </w:t>`)
+	assert.Contains(t, contents["word/document.xml"], `<w:t>click</w:t>`)
+	assert.Contains(t, contents["word/_rels/document.xml.rels"], `Target="media/image1.png"`, "внутренние связи не меняются")
+	assert.Contains(t, contents["word/_rels/document.xml.rels"], `Target="https://**************" TargetMode="External"`)
+
+	for _, name := range []string{"word/document.xml", "word/_rels/document.xml.rels"} {
+		decoder := xml.NewDecoder(bytes.NewReader([]byte(contents[name])))
+		for {
+			_, err := decoder.Token()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err, name)
+		}
+	}
+}
+
+func TestOffice_XlsxCells(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "book.xlsx")
+	output := filepath.Join(dir, "masked.xlsx")
+
+	writeZip(t, input, [][2]string{
+		{"xl/sharedStrings.xml", `<sst count="2"><si><t>http://a.io</t></si><si><t>&lt;plain&gt;</t></si></sst>`},
+		{"xl/worksheets/sheet1.xml", `<worksheet><sheetData><row r="1"><c r="A1" t="inlineStr"><is><t><![CDATA[https://c.io]]></t></is></c>` +
+			`<c r="B1"><v>42</v></c></row></sheetData><hyperlinks><hyperlink ref="A1" r:id="rId1" display="https://c.io"/></hyperlinks></worksheet>`},
+	})
+
+	svc := NewServiceFactory(2, false).CreateMaskService(input, output)
+	require.NoError(t, svc.Run(context.Background()))
+
+	_, contents := readZip(t, output)
+	assert.Equal(t, `<sst count="2"><si><t>http://****</t></si><si><t>&lt;plain&gt;</t></si></sst>`, contents["xl/sharedStrings.xml"])
+	assert.Equal(t, `<worksheet><sheetData><row r="1"><c r="A1" t="inlineStr"><is><t><![CDATA[https://****]]></t></is></c>`+
+		`<c r="B1"><v>42</v></c></row></sheetData><hyperlinks><hyperlink ref="A1" r:id="rId1" display="https://****"/></hyperlinks></worksheet>`,
+		contents["xl/worksheets/sheet1.xml"])
+}
+
+func TestOfficeXML_SplitRuns(t *testing.T) {
+	input := `<w:p><w:r><w:t>see https://a.io/</w:t></w:r><w:r><w:rPr><w:b/></w:rPr><w:t>secret?a=1&amp;b</w:t></w:r>` +
+		`<w:r><w:t xml:space="preserve"> и </w:t></w:r><w:r><w:t>&lt;дальше&gt;</w:t></w:r></w:p>` +
+		`<a:p><a:r><a:t>https://b.io</a:t></a:r><a:r><a:t>/x</a:t></a:r></a:p>`
+	expected := `<w:p><w:r><w:t>see https://*****</w:t></w:r><w:r><w:rPr><w:b/></w:rPr><w:t>************</w:t></w:r>` +
+		`<w:r><w:t xml:space="preserve"> и </w:t></w:r><w:r><w:t>&lt;дальше&gt;</w:t></w:r></w:p>` +
+		`<a:p><a:r><a:t>https://****</a:t></a:r><a:r><a:t>**</a:t></a:r></a:p>`
+	assert.Equal(t, expected, maskDocument(t, parseOfficeXML, input))
+}
+
+func TestSplitRuns(t *testing.T) {
+	runs := []string{"see https://a.io/", "secret", " end"}
+
+	assert.Equal(t, []string{"see https://*****", "******", " end"},
+		splitRuns("see https://*********** end", runs), "длина не изменилась")
+	assert.Equal(t, []string{"see [URL#1]", "", " end"},
+		splitRuns("see [URL#1] end", runs), "замена короче исходного текста")
+	assert.Equal(t, []string{"see https://a.io/", "secret", " end"},
+		splitRuns("see https://a.io/secret end", runs))
+}
+
+func TestDetectFormat_Office(t *testing.T) {
+	for _, name := range []string{"a.docx", "b.XLSX", "c.pptx", "d.docm"} {
+		assert.Equal(t, FormatOffice, DetectFormat(name), name)
+	}
+	format, err := ParseFormat("xlsx")
+	require.NoError(t, err)
+	assert.Equal(t, FormatOffice, format)
+}
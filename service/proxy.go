@@ -0,0 +1,255 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"LinkMaskirator/masking"
+)
+
+// ProxyConfig - настройки маскирующего прокси
+type ProxyConfig struct {
+	// Workers - воркеров на маскировку одного ответа
+	Workers int
+	// MaxBodySize - максимальный размер HTML/JSON ответа, который разбирается целиком
+	MaxBodySize int64
+	// Options - настройки разбора HTML, JSON и Markdown
+	Options FormatOptions
+}
+
+func DefaultProxyConfig() ProxyConfig {
+	return ProxyConfig{
+		Workers:     10,
+		MaxBodySize: 32 << 20,
+		Options:     DefaultFormatOptions(),
+	}
+}
+
+// NewMaskingProxy - обратный прокси, маскирующий ответы upstream на лету.
+// text/plain маскируется построчно по мере получения, HTML, JSON и Markdown
+// разбираются целиком, чтобы не сломать разметку. Ответы в gzip
+// распаковываются и сжимаются обратно, остальные ответы проходят как есть.
+func NewMaskingProxy(upstream *url.URL, masker *masking.Masker, cfg ProxyConfig) http.Handler {
+	if masker == nil {
+		masker = defaultMasker
+	}
+	if cfg.MaxBodySize <= 0 {
+		cfg.MaxBodySize = DefaultProxyConfig().MaxBodySize
+	}
+
+	p := &maskingProxy{masker: masker, cfg: cfg}
+	return &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(upstream)
+			r.SetXForwarded()
+			// Маскировать умеем только несжатый ответ или gzip, а gzip просим,
+			// только если его примет клиент: ответ сжимается обратно тем же способом
+			if acceptsGzip(r.In.Header.Values("Accept-Encoding")) {
+				r.Out.Header.Set("Accept-Encoding", "gzip")
+			} else {
+				r.Out.Header.Set("Accept-Encoding", "identity")
+			}
+			// Часть документа замаскировать корректно нельзя
+			r.Out.Header.Del("Range")
+			r.Out.Header.Del("If-Range")
+		},
+		ModifyResponse: p.modifyResponse,
+		FlushInterval:  -1,
+	}
+}
+
+type maskingProxy struct {
+	masker *masking.Masker
+	cfg    ProxyConfig
+}
+
+func (p *maskingProxy) modifyResponse(resp *http.Response) error {
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	format, stream := proxyFormat(mediaType)
+	if format == "" || resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotModified {
+		return nil
+	}
+
+	encoding := strings.ToLower(resp.Header.Get("Content-Encoding"))
+	if encoding != "" && encoding != "identity" && encoding != "gzip" {
+		slog.Warn("ответ с неподдерживаемым сжатием передан без маскировки",
+			"url", resp.Request.URL.String(), "content-encoding", encoding)
+		return nil
+	}
+	compressed := encoding == "gzip"
+
+	// Содержимое меняется, поэтому старые длина и ETag больше не верны
+	resp.Header.Del("Etag")
+	resp.Header.Del("Content-Md5")
+
+	if resp.Request.Method == http.MethodHead {
+		// Тела нет, Content-Length описывает ответ на GET - оставляем как есть
+		return nil
+	}
+
+	body := io.Reader(resp.Body)
+	if compressed {
+		buffered := bufio.NewReader(resp.Body)
+		if _, err := buffered.Peek(1); err == io.EOF {
+			// Пустое тело (например, 200 без содержимого) - распаковывать и маскировать нечего
+			resp.Body = struct {
+				io.Reader
+				io.Closer
+			}{buffered, resp.Body}
+			return nil
+		}
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			return fmt.Errorf("ошибка распаковки ответа: %w", err)
+		}
+		body = gz
+	}
+
+	if stream {
+		resp.Body = p.streamLines(resp.Body, body, compressed)
+		resp.Header.Del("Content-Length")
+		resp.ContentLength = -1
+		return nil
+	}
+
+	data, err := io.ReadAll(io.LimitReader(body, p.cfg.MaxBodySize+1))
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) > p.cfg.MaxBodySize {
+		return fmt.Errorf("ответ больше %d байт", p.cfg.MaxBodySize)
+	}
+
+	masked, err := maskHTTPBody(resp.Request.Context(), p.masker, p.cfg.Workers, p.cfg.Options, format, data)
+	if err != nil {
+		return err
+	}
+	if compressed {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		if _, err := gz.Write(masked); err != nil {
+			return err
+		}
+		if err := gz.Close(); err != nil {
+			return err
+		}
+		masked = buf.Bytes()
+	}
+
+	resp.Body = io.NopCloser(bytes.NewReader(masked))
+	resp.ContentLength = int64(len(masked))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(masked)))
+	return nil
+}
+
+// acceptsGzip - разрешает ли Accept-Encoding клиента ответ в gzip
+func acceptsGzip(values []string) bool {
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			coding, params, _ := strings.Cut(item, ";")
+			coding = strings.ToLower(strings.TrimSpace(coding))
+			if coding != "gzip" && coding != "x-gzip" && coding != "*" {
+				continue
+			}
+			q := strings.ReplaceAll(strings.ToLower(params), " ", "")
+			if q, ok := strings.CutPrefix(q, "q="); ok {
+				if weight, err := strconv.ParseFloat(q, 64); err == nil && weight == 0 {
+					continue
+				}
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// proxyFormat - как маскировать ответ; stream - построчно по мере получения
+func proxyFormat(mediaType string) (Format, bool) {
+	switch {
+	case mediaType == "text/plain":
+		return FormatText, true
+	case mediaType == "text/html" || mediaType == "application/xhtml+xml":
+		return FormatHTML, false
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		return FormatJSON, false
+	case mediaType == "application/x-ndjson" || mediaType == "application/jsonl":
+		return FormatJSONL, false
+	case mediaType == "text/markdown":
+		return FormatMarkdown, false
+	default:
+		return "", false
+	}
+}
+
+// maskHTTPBody разбирает тело HTTP-сообщения в формате format и маскирует его сегменты
+func maskHTTPBody(ctx context.Context, masker *masking.Masker, workers int, options FormatOptions,
+	format Format, data []byte) ([]byte, error) {
+	doc, err := documentParserFor(format, options)(data)
+	if err != nil {
+		return nil, err
+	}
+	masked, err := maskLines(ctx, masker, workers, doc.Segments())
+	if err != nil {
+		return nil, err
+	}
+	return doc.Render(masked)
+}
+
+// streamLines маскирует текст построчно: строка уходит клиенту, как только
+// upstream ее дослал, поэтому длинные и бесконечные ответы не копятся в памяти
+func (p *maskingProxy) streamLines(upstream io.Closer, body io.Reader, compressed bool) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		defer upstream.Close()
+
+		var out io.Writer = pw
+		var gz *gzip.Writer
+		if compressed {
+			gz = gzip.NewWriter(pw)
+			out = gz
+		}
+
+		reader := bufio.NewReader(body)
+		for {
+			line, err := reader.ReadString('\n')
+			if line != "" {
+				text, eol := cutEOL(line)
+				if _, werr := io.WriteString(out, p.masker.Mask(text)+eol); werr != nil {
+					pw.CloseWithError(werr)
+					return
+				}
+				if gz != nil && reader.Buffered() == 0 {
+					gz.Flush()
+				}
+			}
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+
+		if gz != nil {
+			if err := gz.Close(); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+		pw.Close()
+	}()
+	return pr
+}
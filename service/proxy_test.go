@@ -0,0 +1,169 @@
+package service
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newProxyPair(t *testing.T, upstream http.HandlerFunc) *httptest.Server {
+	t.Helper()
+	backend := httptest.NewServer(upstream)
+	t.Cleanup(backend.Close)
+
+	target, err := url.Parse(backend.URL)
+	require.NoError(t, err)
+	proxy := httptest.NewServer(NewMaskingProxy(target, nil, DefaultProxyConfig()))
+	t.Cleanup(proxy.Close)
+	return proxy
+}
+
+func TestMaskingProxy(t *testing.T) {
+	proxy := newProxyPair(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/page":
+			body := `<a href="https://a.io/x">https://a.io/x</a>`
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			w.Header().Set("ETag", `"v1"`)
+			io.WriteString(w, body)
+		case "/api":
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("X-Accept-Encoding", r.Header.Get("Accept-Encoding"))
+			if r.Header.Get("Accept-Encoding") != "gzip" {
+				io.WriteString(w, `{"url": "http://b.io/api", "n": 1}`)
+				return
+			}
+			w.Header().Set("Content-Encoding", "gzip")
+			gz := gzip.NewWriter(w)
+			io.WriteString(gz, `{"url": "http://b.io/api", "n": 1}`)
+			gz.Close()
+		case "/log":
+			// Чанки: строки приходят по частям
+			w.Header().Set("Content-Type", "text/plain")
+			flusher := w.(http.Flusher)
+			io.WriteString(w, "first http://c.io\nsecond ")
+			flusher.Flush()
+			io.WriteString(w, "https://d.io/y\n")
+		case "/empty":
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Content-Encoding", "gzip")
+			if r.URL.Query().Has("chunked") {
+				// Без Content-Length: длина тела заранее неизвестна
+				w.(http.Flusher).Flush()
+			}
+		case "/image":
+			w.Header().Set("Content-Type", "image/png")
+			io.WriteString(w, "http://e.io")
+		}
+	})
+
+	t.Run("HTML и Content-Length", func(t *testing.T) {
+		resp, err := http.Get(proxy.URL + "/page")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+
+		assert.Equal(t, `<a href="https://******">https://******</a>`, string(data))
+		assert.Equal(t, int64(len(data)), resp.ContentLength)
+		assert.Empty(t, resp.Header.Get("ETag"))
+	})
+
+	t.Run("JSON в gzip", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, proxy.URL+"/api", nil)
+		require.NoError(t, err)
+		req.Header.Set("Accept-Encoding", "gzip")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		require.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+		assert.Equal(t, "gzip", resp.Header.Get("X-Accept-Encoding"))
+		gz, err := gzip.NewReader(resp.Body)
+		require.NoError(t, err)
+		data, err := io.ReadAll(gz)
+		require.NoError(t, err)
+		assert.Equal(t, `{"url": "http://********", "n": 1}`, string(data))
+	})
+
+	t.Run("клиент без gzip получает несжатый ответ", func(t *testing.T) {
+		for _, accept := range []string{"", "identity", "br", "gzip;q=0"} {
+			req, err := http.NewRequest(http.MethodGet, proxy.URL+"/api", nil)
+			require.NoError(t, err)
+			if accept != "" {
+				req.Header.Set("Accept-Encoding", accept)
+			}
+			client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+			resp, err := client.Do(req)
+			require.NoError(t, err)
+			data, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			require.NoError(t, err)
+
+			assert.Equal(t, "identity", resp.Header.Get("X-Accept-Encoding"), accept)
+			assert.Empty(t, resp.Header.Get("Content-Encoding"), accept)
+			assert.Equal(t, `{"url": "http://********", "n": 1}`, string(data), accept)
+		}
+	})
+
+	t.Run("HEAD сохраняет Content-Length", func(t *testing.T) {
+		resp, err := http.Head(proxy.URL + "/page")
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, int64(len(`<a href="https://a.io/x">https://a.io/x</a>`)), resp.ContentLength)
+		assert.Empty(t, resp.Header.Get("ETag"))
+
+		req, err := http.NewRequest(http.MethodHead, proxy.URL+"/api", nil)
+		require.NoError(t, err)
+		req.Header.Set("Accept-Encoding", "gzip")
+		resp, err = http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode, "пустое тело gzip не распаковывается")
+	})
+
+	t.Run("text/plain потоком", func(t *testing.T) {
+		resp, err := http.Get(proxy.URL + "/log")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+
+		assert.Equal(t, "first http://****\nsecond https://******\n", string(data))
+		assert.Equal(t, int64(-1), resp.ContentLength)
+	})
+
+	t.Run("пустой ответ в gzip передается как есть", func(t *testing.T) {
+		for _, path := range []string{"/empty", "/empty?chunked"} {
+			req, err := http.NewRequest(http.MethodGet, proxy.URL+path, nil)
+			require.NoError(t, err)
+			req.Header.Set("Accept-Encoding", "gzip")
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			data, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			require.NoError(t, err)
+
+			assert.Equal(t, http.StatusOK, resp.StatusCode, path)
+			assert.Empty(t, data, path)
+		}
+	})
+
+	t.Run("бинарные ответы не меняются", func(t *testing.T) {
+		resp, err := http.Get(proxy.URL + "/image")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "http://e.io", string(data))
+	})
+}
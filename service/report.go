@@ -0,0 +1,57 @@
+package service
+
+import (
+	"encoding/json"
+	"os"
+
+	"LinkMaskirator/masking"
+)
+
+// Report - итог обработки файла: сколько фрагментов ушло на маскировку
+// и сколько из них изменилось, Allowed - сколько ссылок оставлено открытыми
+// по спискам доменов. Для архивов - результат по каждому файлу.
+type Report struct {
+	Input    string        `json:"input,omitempty"`
+	Output   string        `json:"output,omitempty"`
+	Segments int           `json:"segments"`
+	Masked   int           `json:"masked"`
+	Allowed  int           `json:"allowed,omitempty"`
+	Entries  []EntryReport `json:"entries,omitempty"`
+}
+
+// EntryReport - результат обработки одного файла внутри архива
+type EntryReport struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Format   Format `json:"format,omitempty"`
+	Size     int64  `json:"size"`
+	Segments int    `json:"segments,omitempty"`
+	Masked   int    `json:"masked,omitempty"`
+}
+
+// entryReporter - Presenter, который знает результаты по отдельным файлам
+type entryReporter interface {
+	entryReports() []EntryReport
+}
+
+// WritePlaceholderMap сохраняет в JSON соответствие нумерованных заглушек
+// и исходных значений. В нем открытые данные, поэтому права 0600.
+func WritePlaceholderMap(path string, entries []masking.PlaceholderEntry) error {
+	if entries == nil {
+		entries = []masking.PlaceholderEntry{}
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0600)
+}
+
+// WriteReport сохраняет отчет в JSON
+func WriteReport(path string, report *Report) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
@@ -0,0 +1,291 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"LinkMaskirator/masking"
+)
+
+// ResumableConfig - настройки обработки текстового файла с сохранением позиции
+type ResumableConfig struct {
+	Source string
+	Dest   string
+	// Checkpoint - файл с позицией, по умолчанию Dest + ".resume"
+	Checkpoint string
+	// Resume - продолжить с сохраненной позиции вместо обработки с начала
+	Resume bool
+	// Rules - описание правил маскировки; продолжить можно только с теми же правилами
+	Rules string
+	// ChunkSize - размер куска, который маскируется и фиксируется за раз
+	ChunkSize int
+	Workers   int
+	SlowMode  bool
+	Masker    *masking.Masker
+}
+
+// identityHeadSize - сколько первых байт входного файла входит в его отпечаток
+const identityHeadSize = 64 << 10
+
+// Режимы, сохраняющие позицию: их файлы различаются содержимым, поэтому
+// в файле записывается режим и позиция другого режима не принимается
+const (
+	checkpointResume = "resume"
+	checkpointFollow = "follow"
+)
+
+// runCheckpoint - результат, уже зафиксированный в конечном файле
+type runCheckpoint struct {
+	// Mode - checkpointResume
+	Mode     string `json:"mode"`
+	Source   string `json:"source"`
+	Identity string `json:"identity"`
+	Rules    string `json:"rules"`
+	// Offset - позиция во входном файле после последней зафиксированной строки
+	Offset   int64 `json:"offset"`
+	DestSize int64 `json:"dest_size"`
+	Segments int   `json:"segments"`
+	Masked   int   `json:"masked"`
+	Allowed  int   `json:"allowed,omitempty"`
+}
+
+// SupportsResume - можно ли обработать файл с сохранением позиции:
+// только обычный текст без сжатия на входе и выходе
+func SupportsResume(format Format, source, dest string, gzipLevel int) error {
+	if format == FormatAuto {
+		format = DetectFormat(source)
+	}
+	if format != FormatText {
+		return fmt.Errorf("продолжение поддерживается только для текстовых файлов, а не %s", format)
+	}
+	if gzipLevel != 0 || strings.EqualFold(filepath.Ext(dest), ".gz") {
+		return fmt.Errorf("продолжение не поддерживается при сжатии результата")
+	}
+
+	file, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	header := make([]byte, 4)
+	n, _ := io.ReadFull(file, header)
+	if detectCompression(source, header[:n]) != compressionNone {
+		return fmt.Errorf("продолжение не поддерживается для сжатого входного файла")
+	}
+	return nil
+}
+
+// RunResumable маскирует текстовый файл кусками (см. MaskChunked). После
+// каждого куска результат сбрасывается на диск и сохраняется позиция, поэтому
+// прерванный запуск (сигнал, таймаут) можно продолжить с Resume и получить
+// тот же файл, что и без прерывания. После успешного завершения файл
+// с позицией удаляется. Source и Dest должны быть разными файлами.
+func RunResumable(ctx context.Context, cfg ResumableConfig) (*Report, error) {
+	if cfg.Checkpoint == "" {
+		cfg.Checkpoint = cfg.Dest + ".resume"
+	}
+	if cfg.Masker == nil {
+		cfg.Masker = defaultMasker
+	}
+
+	source, err := os.Open(cfg.Source)
+	if err != nil {
+		return nil, err
+	}
+	defer source.Close()
+
+	// Результат пишется в конечный файл по мере чтения, поэтому запись
+	// в тот же файл уничтожила бы еще не прочитанные строки
+	if err := checkSameFile(source, cfg.Dest); err != nil {
+		return nil, err
+	}
+
+	identity, err := fileIdentity(source)
+	if err != nil {
+		return nil, err
+	}
+	cp := runCheckpoint{Mode: checkpointResume, Source: cfg.Source, Identity: identity, Rules: rulesHash(cfg.Rules)}
+	if cfg.Resume {
+		if cp, err = loadRunCheckpoint(cfg.Checkpoint, cp); err != nil {
+			return nil, err
+		}
+	}
+
+	dest, err := openResumedDest(cfg.Dest, cp.DestSize)
+	if err != nil {
+		return nil, err
+	}
+	defer dest.Close()
+
+	info, err := source.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if cp.Offset > 0 {
+		slog.InfoContext(ctx, "продолжение с сохраненной позиции",
+			"input", cfg.Source, "offset", cp.Offset, "segments", cp.Segments)
+	}
+
+	r := &resumableRun{cfg: cfg, dest: dest, cp: cp}
+	err = maskChunks(ctx, source, cp.Offset, info.Size(), ChunkConfig{
+		Workers:   cfg.Workers,
+		ChunkSize: cfg.ChunkSize,
+		SlowMode:  cfg.SlowMode,
+		Masker:    cfg.Masker,
+	}, r.commit)
+	report := &Report{Segments: r.cp.Segments, Masked: r.cp.Masked, Allowed: r.cp.Allowed}
+	if err != nil {
+		return report, err
+	}
+
+	if err := os.Remove(cfg.Checkpoint); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return report, err
+	}
+	return report, nil
+}
+
+type resumableRun struct {
+	cfg  ResumableConfig
+	dest *os.File
+	cp   runCheckpoint
+}
+
+// commit дописывает готовый кусок в конечный файл и сохраняет позицию
+// после него. Куски приходят по порядку, прерванный кусок не фиксируется
+// и при продолжении обрабатывается заново.
+func (r *resumableRun) commit(out []byte, end int64, stats chunkStats) error {
+	if len(out) > 0 {
+		// Куски соединяются так же, как строки внутри куска
+		if r.cp.DestSize > 0 {
+			if _, err := io.WriteString(r.dest, "\n"); err != nil {
+				return fmt.Errorf("ошибка записи в конечный файл: %w", err)
+			}
+			r.cp.DestSize++
+		}
+		if _, err := r.dest.Write(out); err != nil {
+			return fmt.Errorf("ошибка записи в конечный файл: %w", err)
+		}
+		// Позиция сохраняется только после того, как строки дошли до диска
+		if err := r.dest.Sync(); err != nil {
+			return err
+		}
+		r.cp.DestSize += int64(len(out))
+	}
+	r.cp.Offset = end
+	r.cp.Segments += stats.segments
+	r.cp.Masked += stats.masked
+	r.cp.Allowed += stats.allowed
+
+	data, err := json.Marshal(r.cp)
+	if err != nil {
+		return err
+	}
+	tmp := r.cfg.Checkpoint + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, r.cfg.Checkpoint)
+}
+
+// loadRunCheckpoint читает позицию и проверяет, что она относится к тому же
+// входному файлу и тем же правилам. Без файла позиции обработка идет с начала.
+func loadRunCheckpoint(path string, current runCheckpoint) (runCheckpoint, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		slog.Info("сохраненной позиции нет, обработка с начала", "checkpoint", path)
+		return current, nil
+	}
+	if err != nil {
+		return current, err
+	}
+
+	var cp runCheckpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return current, fmt.Errorf("ошибка чтения позиции %s: %w", path, err)
+	}
+	switch {
+	case cp.Mode != checkpointResume:
+		return current, fmt.Errorf("позиция %s сохранена не для --resume", path)
+	case cp.Source != current.Source:
+		return current, fmt.Errorf("позиция %s сохранена для другого файла: %s", path, cp.Source)
+	case cp.Identity != current.Identity:
+		return current, fmt.Errorf("входной файл изменился после прерванного запуска, продолжить нельзя")
+	case cp.Rules != current.Rules:
+		return current, fmt.Errorf("правила маскировки отличаются от прерванного запуска, продолжить нельзя")
+	}
+	return cp, nil
+}
+
+// checkSameFile возвращает ошибку, если dest - это уже открытый source
+func checkSameFile(source *os.File, dest string) error {
+	destInfo, err := os.Stat(dest)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	sourceInfo, err := source.Stat()
+	if err != nil {
+		return err
+	}
+	if os.SameFile(sourceInfo, destInfo) {
+		return fmt.Errorf("исходный и конечный файл совпадают: %s; с сохранением позиции файл нельзя обработать на месте", dest)
+	}
+	return nil
+}
+
+// openResumedDest открывает конечный файл и отрезает все, что записано
+// после size: эти строки не попали в позицию и будут записаны заново
+func openResumedDest(path string, size int64) (*os.File, error) {
+	dest, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := dest.Stat()
+	if err == nil && info.Size() < size {
+		err = fmt.Errorf("конечный файл %s короче сохраненной позиции: %d < %d", path, info.Size(), size)
+	}
+	if err == nil {
+		err = dest.Truncate(size)
+	}
+	if err == nil {
+		_, err = dest.Seek(size, io.SeekStart)
+	}
+	if err != nil {
+		dest.Close()
+		return nil, err
+	}
+	return dest, nil
+}
+
+// fileIdentity - размер, время изменения и хеш начала файла: полный
+// хеш многогигабайтного файла стоил бы еще одного прохода по нему
+func fileIdentity(file *os.File) (string, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+	head, err := readHead(file, min(info.Size(), identityHeadSize))
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(head)
+	return fmt.Sprintf("%d:%s:%s", info.Size(), info.ModTime().UTC().Format(time.RFC3339Nano), hex.EncodeToString(sum[:])), nil
+}
+
+func rulesHash(rules string) string {
+	sum := sha256.Sum256([]byte(rules))
+	return hex.EncodeToString(sum[:])
+}
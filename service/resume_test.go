@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"LinkMaskirator/masking"
+)
+
+// cancelRule отменяет контекст, когда встречает строку stop
+type cancelRule struct {
+	stop   string
+	cancel context.CancelFunc
+}
+
+func (r cancelRule) Name() string { return "cancel" }
+
+func (r cancelRule) Find(line string) []masking.Match {
+	if line == r.stop {
+		r.cancel()
+	}
+	return nil
+}
+
+func resumeInput(t *testing.T, dir string) string {
+	t.Helper()
+	var b strings.Builder
+	for i := range 500 {
+		fmt.Fprintf(&b, "  line %d http://host%d.io/x  \r\n", i, i)
+		if i%7 == 0 {
+			b.WriteString("\n")
+		}
+	}
+	b.WriteString("last без перевода строки")
+	path := filepath.Join(dir, "input.txt")
+	require.NoError(t, os.WriteFile(path, []byte(b.String()), 0644))
+	return path
+}
+
+func TestRunResumable(t *testing.T) {
+	dir := t.TempDir()
+	input := resumeInput(t, dir)
+
+	// Эталон - обычный запуск через FileProducer и FilePresenter
+	expectedPath := filepath.Join(dir, "expected.txt")
+	require.NoError(t, NewService(NewFileProducer(input), NewFilePresenter(expectedPath)).Run(context.Background()))
+	expected, err := os.ReadFile(expectedPath)
+	require.NoError(t, err)
+
+	dest := filepath.Join(dir, "out.txt")
+	cfg := ResumableConfig{Source: input, Dest: dest, Rules: "link", ChunkSize: 1024, Workers: 4}
+
+	t.Run("без прерывания", func(t *testing.T) {
+		report, err := RunResumable(context.Background(), cfg)
+		require.NoError(t, err)
+		assert.Equal(t, 500, report.Masked)
+		assert.Equal(t, 500+72+1, report.Segments)
+
+		out, err := os.ReadFile(dest)
+		require.NoError(t, err)
+		assert.Equal(t, string(expected), string(out))
+		assert.NoFileExists(t, dest+".resume")
+	})
+
+	t.Run("прерывание и продолжение", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		masker := masking.NewLinkMasker()
+		masker.AddRule(cancelRule{stop: "  line 300 http://host300.io/x  ", cancel: cancel}, nil)
+
+		interrupted := cfg
+		interrupted.Masker = masker
+		_, err := RunResumable(ctx, interrupted)
+		require.ErrorIs(t, err, context.Canceled)
+		require.FileExists(t, dest+".resume")
+
+		partial, err := os.ReadFile(dest)
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(string(expected), string(partial)), "зафиксировано только начало результата")
+		assert.Less(t, len(partial), len(expected))
+
+		// Мусор после зафиксированной позиции отрезается
+		appendFile(t, dest, "\nнедописанная строка")
+
+		resumed := cfg
+		resumed.Resume = true
+		report, err := RunResumable(context.Background(), resumed)
+		require.NoError(t, err)
+		assert.Equal(t, 500, report.Masked, "счетчики продолжаются с сохраненных")
+
+		out, err := os.ReadFile(dest)
+		require.NoError(t, err)
+		assert.Equal(t, string(expected), string(out))
+		assert.NoFileExists(t, dest+".resume")
+	})
+}
+
+func TestRunResumable_Mismatch(t *testing.T) {
+	dir := t.TempDir()
+	input := resumeInput(t, dir)
+	dest := filepath.Join(dir, "out.txt")
+	cfg := ResumableConfig{Source: input, Dest: dest, Rules: "link", ChunkSize: 1024}
+
+	interrupt := func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		masker := masking.NewLinkMasker()
+		masker.AddRule(cancelRule{stop: "  line 100 http://host100.io/x  ", cancel: cancel}, nil)
+		interrupted := cfg
+		interrupted.Masker = masker
+		_, err := RunResumable(ctx, interrupted)
+		require.ErrorIs(t, err, context.Canceled)
+	}
+
+	interrupt()
+	other := cfg
+	other.Resume = true
+	other.Rules = "link,phone"
+	_, err := RunResumable(context.Background(), other)
+	assert.ErrorContains(t, err, "правила")
+
+	appendFile(t, input, "\nновая строка")
+	resumed := cfg
+	resumed.Resume = true
+	_, err = RunResumable(context.Background(), resumed)
+	assert.ErrorContains(t, err, "изменился")
+}
+
+func TestRunResumable_SameFile(t *testing.T) {
+	dir := t.TempDir()
+	input := resumeInput(t, dir)
+	original, err := os.ReadFile(input)
+	require.NoError(t, err)
+
+	link := filepath.Join(dir, "link.txt")
+	require.NoError(t, os.Symlink(input, link))
+
+	for _, dest := range []string{input, link} {
+		_, err := RunResumable(context.Background(), ResumableConfig{Source: input, Dest: dest, Rules: "link"})
+		assert.ErrorContains(t, err, "совпадают")
+	}
+
+	data, err := os.ReadFile(input)
+	require.NoError(t, err)
+	assert.Equal(t, string(original), string(data), "входной файл не затирается")
+	assert.NoFileExists(t, input+".resume")
+}
+
+func TestService_RunInPlace(t *testing.T) {
+	dir := t.TempDir()
+	input := resumeInput(t, dir)
+	expectedPath := filepath.Join(dir, "expected.txt")
+	require.NoError(t, NewService(NewFileProducer(input), NewFilePresenter(expectedPath)).Run(context.Background()))
+
+	// Обычный запуск читает файл целиком до записи, поэтому маскировка на месте работает
+	require.NoError(t, NewService(NewFileProducer(input), NewFilePresenter(input)).Run(context.Background()))
+	expected, err := os.ReadFile(expectedPath)
+	require.NoError(t, err)
+	data, err := os.ReadFile(input)
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), string(data))
+	assert.NoFileExists(t, input+".resume")
+}
+
+func TestSupportsResume(t *testing.T) {
+	dir := t.TempDir()
+	text := filepath.Join(dir, "a.log")
+	require.NoError(t, os.WriteFile(text, []byte("x"), 0644))
+	gz := filepath.Join(dir, "b.log")
+	require.NoError(t, os.WriteFile(gz, []byte{0x1f, 0x8b, 0, 0}, 0644))
+
+	assert.NoError(t, SupportsResume(FormatAuto, text, "out.txt", 0))
+	assert.Error(t, SupportsResume(FormatAuto, text, "out.txt.gz", 0))
+	assert.Error(t, SupportsResume(FormatAuto, text, "out.txt", 6))
+	assert.Error(t, SupportsResume(FormatJSON, text, "out.txt", 0))
+	assert.Error(t, SupportsResume(FormatAuto, gz, "out.txt", 0))
+}
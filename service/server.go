@@ -0,0 +1,304 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"LinkMaskirator/masking"
+)
+
+// ServerConfig - настройки HTTP-режима
+type ServerConfig struct {
+	// Workers - воркеров на один запрос
+	Workers int
+	// MaxBodySize - максимальный размер тела запроса в байтах
+	MaxBodySize int64
+	// Timeout - ограничение времени обработки одного запроса
+	Timeout time.Duration
+	// BatchLines - сколько строк текстового тела маскируется за раз при потоковой обработке
+	BatchLines int
+	// Options - настройки форматов для JSON-запросов с полем format
+	Options FormatOptions
+}
+
+func DefaultServerConfig() ServerConfig {
+	return ServerConfig{
+		Workers:     10,
+		MaxBodySize: 10 << 20,
+		Timeout:     30 * time.Second,
+		BatchLines:  1000,
+		Options:     DefaultFormatOptions(),
+	}
+}
+
+// Server - HTTP-обертка над Service:
+//
+//	POST /mask    - text/plain маскируется потоково пачками строк,
+//	                application/json {"text": "...", "format": "markdown"} - целиком
+//	POST /check   - есть ли в тексте что маскировать (без самих значений)
+//	GET  /healthz - проверка живости
+type Server struct {
+	cfg    ServerConfig
+	masker *masking.Masker
+}
+
+func NewServer(masker *masking.Masker, cfg ServerConfig) *Server {
+	if masker == nil {
+		masker = defaultMasker
+	}
+	defaults := DefaultServerConfig()
+	if cfg.MaxBodySize <= 0 {
+		cfg.MaxBodySize = defaults.MaxBodySize
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaults.Timeout
+	}
+	if cfg.BatchLines <= 0 {
+		cfg.BatchLines = defaults.BatchLines
+	}
+	return &Server{cfg: cfg, masker: masker}
+}
+
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /mask", s.handleMask)
+	mux.HandleFunc("POST /check", s.handleCheck)
+	mux.HandleFunc("GET /healthz", s.handleHealth)
+	return mux
+}
+
+type maskRequest struct {
+	Text   string `json:"text"`
+	Format Format `json:"format,omitempty"`
+}
+
+type maskResponse struct {
+	Text   string `json:"text"`
+	Masked int    `json:"masked"`
+}
+
+type checkMatch struct {
+	Rule  string `json:"rule"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+type checkResponse struct {
+	Found   bool         `json:"found"`
+	Count   int          `json:"count"`
+	Matches []checkMatch `json:"matches"`
+}
+
+func (s *Server) handleMask(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), s.cfg.Timeout)
+	defer cancel()
+	r.Body = http.MaxBytesReader(w, r.Body, s.cfg.MaxBodySize)
+
+	if isJSONRequest(r) {
+		s.maskJSON(ctx, w, r)
+		return
+	}
+	s.maskStream(ctx, w, r)
+}
+
+// maskStream маскирует текст пачками по BatchLines строк и сразу отправляет
+// результат, поэтому большое тело не держится в памяти целиком.
+// Переводы строк сохраняются как в запросе.
+func (s *Server) maskStream(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	reader := bufio.NewReader(r.Body)
+	flusher, _ := w.(http.Flusher)
+	written := false
+
+	for {
+		lines, eols, readErr := readLineBatch(reader, s.cfg.BatchLines)
+		if readErr != nil && !errors.Is(readErr, io.EOF) {
+			s.streamError(ctx, w, written, readErr)
+			return
+		}
+
+		if len(lines) > 0 || !written {
+			masked, err := s.mask(ctx, lines)
+			if err != nil {
+				s.streamError(ctx, w, written, err)
+				return
+			}
+
+			if !written {
+				w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+				written = true
+			}
+			for i, line := range masked {
+				io.WriteString(w, line)
+				io.WriteString(w, eols[i])
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+
+		if readErr != nil {
+			return
+		}
+	}
+}
+
+// streamError - до начала ответа возвращаем код ошибки, после - обрываем ответ,
+// чтобы клиент не принял обрезанный текст за полный
+func (s *Server) streamError(ctx context.Context, w http.ResponseWriter, written bool, err error) {
+	if !written {
+		writeError(w, err)
+		return
+	}
+	slog.WarnContext(ctx, "ответ прерван", "error", err)
+	panic(http.ErrAbortHandler)
+}
+
+func (s *Server) maskJSON(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var req maskRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	format, err := ParseFormat(string(req.Format))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	parse := documentParserFor(format, s.cfg.Options)
+	if parse == nil {
+		if format != FormatAuto && format != FormatText {
+			writeError(w, fmt.Errorf("формат %q не поддерживается в HTTP-режиме", format))
+			return
+		}
+		parse = parseText
+	}
+
+	doc, err := parse([]byte(req.Text))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	segments := doc.Segments()
+	masked, err := s.mask(ctx, segments)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	out, err := doc.Render(masked)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	resp := maskResponse{Text: string(out)}
+	for i := range masked {
+		if masked[i] != segments[i] {
+			resp.Masked++
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handleCheck(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.cfg.MaxBodySize)
+
+	var text string
+	if isJSONRequest(r) {
+		var req maskRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, err)
+			return
+		}
+		text = req.Text
+	} else {
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		text = string(data)
+	}
+
+	resp := checkResponse{Matches: []checkMatch{}}
+	for _, match := range s.masker.Find(text) {
+		resp.Matches = append(resp.Matches, checkMatch{Rule: match.Rule, Start: match.Start, End: match.End})
+	}
+	resp.Count = len(resp.Matches)
+	resp.Found = resp.Count > 0
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (s *Server) mask(ctx context.Context, lines []string) ([]string, error) {
+	return maskLines(ctx, s.masker, s.cfg.Workers, lines)
+}
+
+// maskLines прогоняет строки из памяти через Service с пулом воркеров
+func maskLines(ctx context.Context, masker *masking.Masker, workers int, lines []string) ([]string, error) {
+	presenter := NewMemoryPresenter()
+	svc := NewService(NewMemoryProducer(lines), presenter)
+	svc.SetWorkers(workers)
+	svc.SetMasker(masker)
+
+	if err := svc.Run(ctx); err != nil {
+		return nil, err
+	}
+	return presenter.Lines(), nil
+}
+
+// readLineBatch читает до limit строк, отделяя переводы строк от содержимого
+func readLineBatch(reader *bufio.Reader, limit int) ([]string, []string, error) {
+	var lines, eols []string
+	for len(lines) < limit {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			body, eol := cutEOL(line)
+			lines = append(lines, body)
+			eols = append(eols, eol)
+		}
+		if err != nil {
+			return lines, eols, err
+		}
+	}
+	return lines, eols, nil
+}
+
+func isJSONRequest(r *http.Request) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	var maxBytes *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytes):
+		status = http.StatusRequestEntityTooLarge
+	case errors.Is(err, context.DeadlineExceeded):
+		status = http.StatusGatewayTimeout
+	case errors.Is(err, context.Canceled):
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		slog.Debug("ошибка отправки ответа", "error", err)
+	}
+}
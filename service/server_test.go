@@ -0,0 +1,133 @@
+package service
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"LinkMaskirator/masking"
+)
+
+func newTestServer(t *testing.T, cfg ServerConfig) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewServer(NewServer(nil, cfg).Handler())
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestServer_MaskText(t *testing.T) {
+	cfg := DefaultServerConfig()
+	cfg.BatchLines = 2 // несколько пачек на запрос
+	ts := newTestServer(t, cfg)
+
+	body := "one http://a.io\r\n  two\n\nthree https://b.io/x\nlast"
+	resp, err := http.Post(ts.URL+"/mask", "text/plain", strings.NewReader(body))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "one http://****\r\n  two\n\nthree https://******\nlast", string(data))
+}
+
+func TestServer_MaskJSON(t *testing.T) {
+	ts := newTestServer(t, DefaultServerConfig())
+
+	resp, err := http.Post(ts.URL+"/mask", "application/json",
+		strings.NewReader(`{"text": "[docs](https://a.io) и http://b.io", "format": "markdown"}`))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var result maskResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	assert.Equal(t, "[docs](https://****) и http://****", result.Text)
+	assert.Equal(t, 2, result.Masked)
+
+	resp, err = http.Post(ts.URL+"/mask", "application/json", strings.NewReader(`{"text": "x", "format": "zip"}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "архивы в HTTP-режиме не поддерживаются")
+}
+
+func TestServer_Check(t *testing.T) {
+	ts := newTestServer(t, DefaultServerConfig())
+
+	resp, err := http.Post(ts.URL+"/check", "text/plain", strings.NewReader("see http://a.io now"))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var result checkResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	assert.True(t, result.Found)
+	assert.Equal(t, []checkMatch{{Rule: "link", Start: 4, End: 15}}, result.Matches)
+
+	resp, err = http.Post(ts.URL+"/check", "application/json", strings.NewReader(`{"text": "ничего"}`))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	result = checkResponse{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	assert.False(t, result.Found)
+	assert.Empty(t, result.Matches)
+}
+
+func TestServer_Limits(t *testing.T) {
+	cfg := DefaultServerConfig()
+	cfg.MaxBodySize = 16
+	ts := newTestServer(t, cfg)
+
+	t.Run("размер тела", func(t *testing.T) {
+		resp, err := http.Post(ts.URL+"/mask", "text/plain", strings.NewReader(strings.Repeat("http://a.io\n", 10)))
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+	})
+
+	t.Run("метод", func(t *testing.T) {
+		resp, err := http.Get(ts.URL + "/mask")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	})
+
+	t.Run("healthz", func(t *testing.T) {
+		resp, err := http.Get(ts.URL + "/healthz")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+}
+
+// slowRule - правило, которое ждет дольше таймаута запроса
+type slowRule struct{}
+
+func (slowRule) Name() string { return "slow" }
+
+func (slowRule) Find(line string) []masking.Match {
+	time.Sleep(200 * time.Millisecond)
+	return nil
+}
+
+func TestServer_Timeout(t *testing.T) {
+	masker := masking.NewMasker()
+	masker.AddRule(slowRule{}, nil)
+
+	cfg := DefaultServerConfig()
+	cfg.Workers = 1
+	cfg.Timeout = 50 * time.Millisecond
+	ts := httptest.NewServer(NewServer(masker, cfg).Handler())
+	defer ts.Close()
+
+	resp, err := http.Post(ts.URL+"/mask", "application/json", strings.NewReader(`{"text": "a\nb\nc"}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusGatewayTimeout, resp.StatusCode)
+}
@@ -4,10 +4,11 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
-	"strings"
 	"sync"
 	"time"
 	// "sync"
+
+	"LinkMaskirator/masking"
 )
 
 type Producer interface {
@@ -23,6 +24,8 @@ type Service struct {
 	_pres     Presenter
 	_workers  int
 	_slowmode bool
+	_masker   *masking.Masker
+	_report   *Report
 }
 
 func NewService(prod Producer, pres Presenter) *Service {
@@ -31,6 +34,7 @@ func NewService(prod Producer, pres Presenter) *Service {
 		_pres:     pres,
 		_workers:  10,
 		_slowmode: false,
+		_masker:   defaultMasker,
 	}
 }
 
@@ -52,41 +56,38 @@ func (s *Service) CheckSlowMode() bool {
 	return s._slowmode
 }
 
-func maskLink(message string) string {
-	runes := []rune(message)
-	lower := []rune(strings.ToLower(message))
-
-	schemes := [][]rune{
-		[]rune("http://"),
-		[]rune("https://"),
+// SetMasker задает набор правил маскировки. По умолчанию маскируются только ссылки.
+func (s *Service) SetMasker(masker *masking.Masker) {
+	if masker != nil {
+		s._masker = masker
 	}
+}
 
-	for i := 0; i < len(lower); i++ {
-		for _, scheme := range schemes {
-			if i+len(scheme) > len(lower) {
-				continue
-			}
-			match := true
-			for j := range scheme {
-				if lower[i+j] != scheme[j] {
-					match = false
-					break
-				}
-			}
+func (s *Service) GetMasker() *masking.Masker {
+	return s._masker
+}
 
-			if !match {
-				continue
-			}
+// Report возвращает отчет о последнем запуске Run, nil - если запуска не было
+func (s *Service) Report() *Report {
+	return s._report
+}
 
-			start := i + len(scheme)
-			for k := start; k < len(runes) && runes[k] != ' '; k++ {
-				runes[k] = '*'
-			}
-		}
+var defaultMasker = masking.NewLinkMasker()
 
-	}
+func maskLink(message string) string {
+	return defaultMasker.Mask(message)
+}
 
-	return string(runes)
+// lineJob - строка вместе с ее позицией во входных данных,
+// чтобы результат собирался в исходном порядке независимо от скорости воркеров
+type lineJob struct {
+	index int
+	line  string
+	// allowed - сколько ссылок в строке оставлено открытыми по спискам доменов
+	allowed int
+	// matches - найденные совпадения, если замена откладывается до сборки
+	// результата (Masker.Ordered)
+	matches []masking.Match
 }
 
 func (s *Service) Run(ctx context.Context) error {
@@ -100,8 +101,8 @@ func (s *Service) Run(ctx context.Context) error {
 	}
 
 	workersCount := s.GetWorkers()
-	origLinesChan := make(chan string)
-	resultLinesChan := make(chan string)
+	origLinesChan := make(chan lineJob)
+	resultLinesChan := make(chan lineJob)
 
 	// А что если у нас меньше строк? Нафига тогда 10 воркеров?
 	if workersCount > len(data) {
@@ -111,26 +112,28 @@ func (s *Service) Run(ctx context.Context) error {
 
 	for i := 0; i < workersCount; i++ {
 		wg.Add(1)
-		go s.Worker(ctx, origLinesChan, resultLinesChan, &wg)
+		go s.worker(ctx, origLinesChan, resultLinesChan, &wg)
 	}
 
 	// Отправляю строки для маскировки в канал.
 	go func() {
 		defer close(origLinesChan)
-		for _, line := range data {
+		for i, line := range data {
 			select {
 			case <-ctx.Done():
 				slog.DebugContext(ctx, "прекращена отправка данных для маскировки")
 				return
-			default:
-				origLinesChan <- line
+			case origLinesChan <- lineJob{index: i, line: line}:
 			}
 
 		}
 
 	}()
 
-	maskedLines := make([]string, 0, len(data))
+	results := make([]string, len(data))
+	matches := make([][]masking.Match, len(data))
+	allowed := make([]int, len(data))
+	ready := make([]bool, len(data))
 	var collectWg sync.WaitGroup
 	collectWg.Add(1)
 
@@ -141,8 +144,11 @@ func (s *Service) Run(ctx context.Context) error {
 			case <-ctx.Done():
 				slog.DebugContext(ctx, "прекращается отправка замаскированных данных")
 				return
-			case resultLine := <-resultLinesChan:
-				maskedLines = append(maskedLines, resultLine)
+			case result := <-resultLinesChan:
+				results[result.index] = result.line
+				matches[result.index] = result.matches
+				allowed[result.index] = result.allowed
+				ready[result.index] = true
 			}
 		}
 	}()
@@ -152,11 +158,39 @@ func (s *Service) Run(ctx context.Context) error {
 
 	collectWg.Wait()
 
-	if len(maskedLines) > 0 {
+	// При прерывании сохраняем только непрерывное начало данных,
+	// чтобы в результат не попали строки с "дырами" между ними
+	done := 0
+	for done < len(ready) && ready[done] {
+		done++
+	}
+	maskedLines := results[:done]
+
+	// Нумерованные заглушки выдаются здесь, по порядку строк, а не в
+	// воркерах: иначе номера зависели бы от того, какой воркер успел первым
+	if masker := s.GetMasker(); masker.Ordered() {
+		for i := range maskedLines {
+			maskedLines[i] = masker.Replace(data[i], matches[i])
+		}
+	}
+
+	report := &Report{Segments: len(data)}
+	for i, line := range maskedLines {
+		if line != data[i] {
+			report.Masked++
+		}
+		report.Allowed += allowed[i]
+	}
+	s._report = report
+
+	if len(maskedLines) > 0 || len(data) == 0 {
 		if err := s._pres.Present(maskedLines); err != nil {
 			slog.DebugContext(ctx, "ошибка сохранения данных в файл", "error", err)
 			return fmt.Errorf("ошибка сохранения: %w", err)
 		}
+		if reporter, ok := s._pres.(entryReporter); ok {
+			report.Entries = reporter.entryReports()
+		}
 		slog.InfoContext(ctx, "результаты сохранены",
 			"lines_saved", len(maskedLines),
 			"total", len(data))
@@ -172,10 +206,14 @@ func (s *Service) Run(ctx context.Context) error {
 
 }
 
-func (s *Service) Worker(ctx context.Context, origLinesChan <-chan string, resultLinesChan chan<- string, wg *sync.WaitGroup) {
+// worker маскирует строки из origLinesChan и отдает их вместе с индексом.
+// Канал работает с внутренним lineJob, поэтому воркер доступен только через Run.
+func (s *Service) worker(ctx context.Context, origLinesChan <-chan lineJob, resultLinesChan chan<- lineJob, wg *sync.WaitGroup) {
 	defer wg.Done()
 	isSlowMode := s.CheckSlowMode()
-	for orLine := range origLinesChan {
+	masker := s.GetMasker()
+	ordered := masker.Ordered()
+	for job := range origLinesChan {
 		select {
 		case <-ctx.Done():
 			return
@@ -187,7 +225,16 @@ func (s *Service) Worker(ctx context.Context, origLinesChan <-chan string, resul
 					return
 				}
 			}
-			resultLinesChan <- maskLink(orLine)
+			if ordered {
+				job.matches, job.allowed = masker.FindAllowed(job.line)
+			} else {
+				job.line, job.allowed = masker.MaskAllowed(job.line)
+			}
+			select {
+			case resultLinesChan <- job:
+			case <-ctx.Done():
+				return
+			}
 
 		}
 
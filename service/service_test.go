@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"fmt"
 	"testing"
 	"time"
 
@@ -431,6 +432,72 @@ func TestWorkerPool(t *testing.T) {
 		mockPresenter.AssertExpectations(t)
 	})
 
+	t.Run("порядок строк сохраняется", func(t *testing.T) {
+		mockProducer := new(MockProducer)
+		mockPresenter := new(MockPresenter)
+
+		var inputLines, expected []string
+		for i := 0; i < 50; i++ {
+			inputLines = append(inputLines, fmt.Sprintf("%d http://example.com/%d", i, i))
+			expected = append(expected, maskLink(inputLines[i]))
+		}
+
+		mockProducer.On("Produce").Return(inputLines, nil)
+		mockPresenter.On("Present", expected).Return(nil)
+
+		service := NewService(mockProducer, mockPresenter)
+		service.SetWorkers(8)
+
+		err := service.Run(context.Background())
+
+		assert.NoError(t, err)
+		mockPresenter.AssertExpectations(t)
+	})
+
+	t.Run("при прерывании сохраняется только непрерывное начало", func(t *testing.T) {
+		mockProducer := new(MockProducer)
+		mockPresenter := new(MockPresenter)
+
+		var inputLines, expected []string
+		for i := 0; i < 8; i++ {
+			inputLines = append(inputLines, fmt.Sprintf("%d http://example.com/%d", i, i))
+			expected = append(expected, maskLink(inputLines[i]))
+		}
+
+		mockProducer.On("Produce").Return(inputLines, nil)
+		mockPresenter.On("Present", mock.MatchedBy(func(lines []string) bool {
+			return len(lines) > 0 && len(lines) < len(expected) &&
+				assert.ObjectsAreEqual(expected[:len(lines)], lines)
+		})).Return(nil)
+
+		service := NewService(mockProducer, mockPresenter)
+		service.SetWorkers(4)
+		service.SetSlowMode(true)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 150*time.Millisecond)
+		defer cancel()
+
+		err := service.Run(ctx)
+
+		assert.Equal(t, context.DeadlineExceeded, err)
+		mockPresenter.AssertExpectations(t)
+	})
+
+	t.Run("пустой вход сохраняется как пустой результат", func(t *testing.T) {
+		mockProducer := new(MockProducer)
+		mockPresenter := new(MockPresenter)
+
+		mockProducer.On("Produce").Return([]string{}, nil)
+		mockPresenter.On("Present", []string{}).Return(nil)
+
+		service := NewService(mockProducer, mockPresenter)
+
+		err := service.Run(context.Background())
+
+		assert.NoError(t, err)
+		mockPresenter.AssertExpectations(t)
+	})
+
 	t.Run("обработка с slowmode=true и несколькими workers", func(t *testing.T) {
 		mockProducer := new(MockProducer)
 		mockPresenter := new(MockPresenter)
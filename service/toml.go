@@ -0,0 +1,263 @@
+package service
+
+import (
+	"strconv"
+	"strings"
+)
+
+// tomlParser - построчный разбор TOML без внешних зависимостей: заголовки таблиц,
+// пары key = value, многострочные строки и массивы. Маскируется содержимое строковых
+// значений (без кавычек) и комментарии, остальной текст не меняется. Ключи встроенных
+// таблиц и номера элементов массивов продолжают путь ключа для --key-path.
+type tomlParser struct {
+	selectors []jsonSelector
+	src       string
+	regions   []configRegion
+
+	table       []jsonPathElem
+	arrayTables map[string]int
+}
+
+// tomlFrame - незакрытый массив или встроенная таблица внутри значения.
+// Строки внутри получают путь frame.path плюс номер элемента или ключ таблицы.
+type tomlFrame struct {
+	path  []jsonPathElem
+	table bool
+	index int            // номер текущего элемента массива
+	key   []jsonPathElem // текущий ключ встроенной таблицы; nil - ключ еще не прочитан
+}
+
+func newTOMLParser(opts FormatOptions) documentParser {
+	return func(data []byte) (*document, error) {
+		selectors, err := parseJSONSelectors(opts.KeyPaths)
+		if err != nil {
+			return nil, err
+		}
+
+		p := &tomlParser{selectors: selectors, src: string(data), arrayTables: make(map[string]int)}
+		p.parse()
+		return configDocument(p.src, p.regions), nil
+	}
+}
+
+func (p *tomlParser) parse() {
+	s := p.src
+	var valuePath []jsonPathElem // путь ключа, значение которого продолжается на следующих строках
+	var stack []tomlFrame        // незакрытые массивы и встроенные таблицы
+
+	for pos := 0; pos < len(s); {
+		lineEnd := strings.IndexByte(s[pos:], '\n')
+		if lineEnd < 0 {
+			lineEnd = len(s)
+		} else {
+			lineEnd += pos
+		}
+
+		i := skipTOMLSpaces(s, pos, lineEnd)
+		switch {
+		case len(stack) > 0:
+			i = p.value(i, lineEnd, valuePath, &stack)
+		case i < lineEnd && s[i] == '[':
+			i = p.header(i, lineEnd)
+		case i < lineEnd && s[i] != '#' && s[i] != '\r':
+			key, next := parseTOMLKey(s, i, lineEnd)
+			if next < lineEnd && s[next] == '=' {
+				valuePath = append(p.table[:len(p.table):len(p.table)], key...)
+				i = p.value(skipTOMLSpaces(s, next+1, lineEnd), lineEnd, valuePath, &stack)
+			} else {
+				i = next
+			}
+		}
+
+		if i > lineEnd {
+			// Многострочная строка закончилась на одной из следующих строк
+			lineEnd = strings.IndexByte(s[i:], '\n')
+			if lineEnd < 0 {
+				lineEnd = len(s)
+			} else {
+				lineEnd += i
+			}
+		}
+		p.comment(i, lineEnd)
+		pos = lineEnd + 1
+	}
+}
+
+// header - "[a.b]" или "[[a.b]]"; для массива таблиц в путь добавляется номер элемента
+func (p *tomlParser) header(i, lineEnd int) int {
+	s := p.src
+	array := strings.HasPrefix(s[i:], "[[")
+	start := i + 1
+	if array {
+		start++
+	}
+
+	key, next := parseTOMLKey(s, start, lineEnd)
+	p.table = key
+	if array {
+		name := strings.TrimSpace(s[start:next])
+		p.table = append(key[:len(key):len(key)], jsonPathElem{index: p.arrayTables[name]})
+		p.arrayTables[name]++
+	}
+
+	for next < lineEnd && s[next] == ']' {
+		next++
+	}
+	return next
+}
+
+// value разбирает значение до конца строки (или до конца многострочной строки).
+// Возвращает позицию, с которой может начинаться комментарий.
+func (p *tomlParser) value(i, lineEnd int, path []jsonPathElem, stack *[]tomlFrame) int {
+	s := p.src
+	for i < lineEnd {
+		if n := len(*stack); n > 0 && (*stack)[n-1].table && (*stack)[n-1].key == nil &&
+			!strings.ContainsRune(" \t\r#,}", rune(s[i])) {
+			// Ключ внутри встроенной таблицы: { a.b = ... }
+			key, next := parseTOMLKey(s, i, lineEnd)
+			if next < lineEnd && s[next] == '=' {
+				(*stack)[n-1].key = key
+				next++
+			}
+			i = max(next, i+1)
+			continue
+		}
+
+		current := tomlValuePath(path, *stack)
+		switch c := s[i]; {
+		case c == '#':
+			return i
+		case c == '[' || c == '{':
+			*stack = append(*stack, tomlFrame{path: current, table: c == '{'})
+			i++
+		case c == ']' || c == '}':
+			if n := len(*stack); n > 0 {
+				*stack = (*stack)[:n-1]
+			}
+			i++
+		case c == ',':
+			if n := len(*stack); n > 0 {
+				if (*stack)[n-1].table {
+					(*stack)[n-1].key = nil
+				} else {
+					(*stack)[n-1].index++
+				}
+			}
+			i++
+		case strings.HasPrefix(s[i:], `"""`) || strings.HasPrefix(s[i:], "'''"):
+			delim := s[i : i+3]
+			end := strings.Index(s[i+3:], delim)
+			if end < 0 {
+				end = len(s)
+			} else {
+				end += i + 3
+			}
+			// Кавычки вплотную к закрывающему разделителю относятся к содержимому
+			for end+3 < len(s) && s[end+3] == delim[0] {
+				end++
+			}
+			p.stringRegion(i+3, end, current)
+			i = min(end+3, len(s))
+			if i > lineEnd {
+				return i
+			}
+		case c == '"' || c == '\'':
+			end := i + 1
+			for end < lineEnd && s[end] != c {
+				if c == '"' && s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			p.stringRegion(i+1, min(end, lineEnd), current)
+			i = min(end+1, lineEnd)
+		default:
+			i++
+		}
+	}
+	return i
+}
+
+// tomlValuePath - путь текущего значения с учетом незакрытых массивов и таблиц
+func tomlValuePath(path []jsonPathElem, stack []tomlFrame) []jsonPathElem {
+	if len(stack) == 0 {
+		return path
+	}
+	top := stack[len(stack)-1]
+	base := top.path[:len(top.path):len(top.path)]
+	if top.table {
+		return append(base, top.key...)
+	}
+	return append(base, jsonPathElem{index: top.index})
+}
+
+func (p *tomlParser) stringRegion(start, end int, path []jsonPathElem) {
+	if start >= end || !selectedPath(p.selectors, path) {
+		return
+	}
+	p.regions = append(p.regions, configRegion{start: start, end: end, value: p.src[start:end]})
+}
+
+func (p *tomlParser) comment(i, lineEnd int) {
+	s := p.src
+	for ; i < lineEnd; i++ {
+		if s[i] == '#' {
+			end := lineEnd
+			if end > i+1 && s[end-1] == '\r' {
+				end--
+			}
+			p.regions = append(p.regions, configRegion{start: i + 1, end: end, value: s[i+1 : end]})
+			return
+		}
+	}
+}
+
+// parseTOMLKey разбирает ключ "a.b", "a . \"b.c\"" до '=' или ']'
+func parseTOMLKey(s string, i, lineEnd int) ([]jsonPathElem, int) {
+	var key []jsonPathElem
+	for i < lineEnd {
+		i = skipTOMLSpaces(s, i, lineEnd)
+		if i >= lineEnd || s[i] == '=' || s[i] == ']' || s[i] == '#' {
+			break
+		}
+
+		var part string
+		switch s[i] {
+		case '"', '\'':
+			end := i + 1
+			for end < lineEnd && s[end] != s[i] {
+				if s[i] == '"' && s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			part = s[i+1 : min(end, lineEnd)]
+			if s[i] == '"' {
+				if unquoted, err := strconv.Unquote(s[i:min(end+1, lineEnd)]); err == nil {
+					part = unquoted
+				}
+			}
+			i = end + 1
+		default:
+			start := i
+			for i < lineEnd && !strings.ContainsRune(" \t.=]#", rune(s[i])) {
+				i++
+			}
+			part = s[start:i]
+		}
+		key = append(key, jsonPathElem{key: part, index: -1})
+
+		i = skipTOMLSpaces(s, i, lineEnd)
+		if i < lineEnd && s[i] == '.' {
+			i++
+		}
+	}
+	return key, i
+}
+
+func skipTOMLSpaces(s string, i, lineEnd int) int {
+	for i < lineEnd && (s[i] == ' ' || s[i] == '\t') {
+		i++
+	}
+	return i
+}
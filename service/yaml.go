@@ -0,0 +1,330 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configRegion - фрагмент конфигурационного файла, который уходит на маскировку
+type configRegion struct {
+	start, end int
+	value      string
+	encode     func(masked, original string) string
+}
+
+// configDocument собирает документ из исходного текста и найденных фрагментов
+func configDocument(src string, regions []configRegion) *document {
+	sort.Slice(regions, func(i, j int) bool { return regions[i].start < regions[j].start })
+
+	doc := &document{}
+	prev := 0
+	for _, region := range regions {
+		if region.start < prev {
+			continue
+		}
+		doc.addText(src[prev:region.start])
+		if region.value == "" {
+			doc.addText(src[region.start:region.end])
+		} else {
+			doc.addEncodedSegment(region.value, region.encode)
+		}
+		prev = region.end
+	}
+	doc.addText(src[prev:])
+	return doc
+}
+
+// yamlParser находит строковые значения через gopkg.in/yaml.v3, но правит
+// исходный текст по позициям узлов, поэтому отступы, комментарии и стиль
+// кавычек сохраняются. Комментарии маскируются как обычный текст.
+type yamlParser struct {
+	selectors []jsonSelector
+	src       string
+	lines     []int // смещения начала строк
+	blockRows map[int]bool
+	regions   []configRegion
+}
+
+func newYAMLParser(opts FormatOptions) documentParser {
+	return func(data []byte) (*document, error) {
+		selectors, err := parseJSONSelectors(opts.KeyPaths)
+		if err != nil {
+			return nil, err
+		}
+
+		p := &yamlParser{
+			selectors: selectors,
+			src:       string(data),
+			lines:     lineOffsets(string(data)),
+			blockRows: make(map[int]bool),
+		}
+
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		for {
+			var node yaml.Node
+			err := decoder.Decode(&node)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			p.walk(&node, nil)
+		}
+
+		p.comments()
+		return configDocument(p.src, p.regions), nil
+	}
+}
+
+func (p *yamlParser) walk(node *yaml.Node, path []jsonPathElem) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			p.walk(child, path)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			p.walk(node.Content[i+1], append(path[:len(path):len(path)], jsonPathElem{key: key, index: -1}))
+		}
+	case yaml.SequenceNode:
+		for i, child := range node.Content {
+			p.walk(child, append(path[:len(path):len(path)], jsonPathElem{index: i}))
+		}
+	case yaml.ScalarNode:
+		if node.Tag == "!!str" && selectedPath(p.selectors, path) {
+			p.scalar(node)
+		}
+	}
+}
+
+func (p *yamlParser) scalar(node *yaml.Node) {
+	if node.Line < 1 || node.Line > len(p.lines) {
+		return
+	}
+	lineStart := p.lines[node.Line-1]
+	lineEnd := p.lineEnd(node.Line - 1)
+	start := runeOffset(p.src, lineStart, lineEnd, node.Column-1)
+
+	switch node.Style {
+	case yaml.LiteralStyle, yaml.FoldedStyle:
+		p.blockScalar(node.Line)
+	case yaml.DoubleQuotedStyle, yaml.SingleQuotedStyle:
+		quote := byte('"')
+		if node.Style == yaml.SingleQuotedStyle {
+			quote = '\''
+		}
+		start = strings.IndexByte(p.src[start:], quote) + start
+		end := closingYAMLQuote(p.src, start)
+		raw := p.src[start:end]
+		p.regions = append(p.regions, configRegion{start: start, end: end, value: node.Value,
+			encode: func(masked, original string) string {
+				if masked == original {
+					return raw
+				}
+				if quote == '\'' {
+					return "'" + strings.ReplaceAll(masked, "'", "''") + "'"
+				}
+				return encodeJSONString(masked)
+			}})
+	default:
+		// Простой скаляр: ищем значение в строке, начиная с позиции узла (она может
+		// указывать на якорь или тег перед значением)
+		idx := strings.Index(p.src[start:lineEnd], node.Value)
+		if idx < 0 {
+			p.multilinePlain(node, start)
+			return
+		}
+		start += idx
+		p.regions = append(p.regions, configRegion{start: start, end: start + len(node.Value), value: node.Value,
+			encode: func(masked, original string) string {
+				if masked == original || yamlPlainSafe(masked) {
+					return masked
+				}
+				return encodeJSONString(masked)
+			}})
+	}
+}
+
+// multilinePlain - простой скаляр на нескольких строках. Парсер склеивает строки
+// через пробел, поэтому значение ищется по словам, а на маскировку уходит исходный
+// фрагмент целиком, с переводами строк и отступами.
+func (p *yamlParser) multilinePlain(node *yaml.Node, from int) {
+	words := strings.Fields(node.Value)
+	if len(words) == 0 {
+		return
+	}
+	start := strings.Index(p.src[from:], words[0])
+	if start < 0 {
+		return
+	}
+	start += from
+
+	end := start + len(words[0])
+	for _, word := range words[1:] {
+		next := end
+		for next < len(p.src) && strings.IndexByte(" \t\r\n", p.src[next]) >= 0 {
+			next++
+		}
+		if !strings.HasPrefix(p.src[next:], word) {
+			return
+		}
+		end = next + len(word)
+	}
+
+	raw := p.src[start:end]
+	p.regions = append(p.regions, configRegion{start: start, end: end, value: raw,
+		encode: func(masked, original string) string {
+			if masked == original {
+				return masked
+			}
+			for _, line := range strings.Split(masked, "\n") {
+				if !yamlPlainSafe(strings.TrimSpace(line)) {
+					return encodeJSONString(strings.Join(strings.Fields(masked), " "))
+				}
+			}
+			return masked
+		}})
+}
+
+// blockScalar - строки блока "|" или ">" маскируются по одной, отступ сохраняется
+func (p *yamlParser) blockScalar(headerLine int) {
+	indent := -1
+	for row := headerLine; row < len(p.lines); row++ {
+		line := p.src[p.lines[row]:p.lineEnd(row)]
+		trimmed := strings.TrimLeft(line, " ")
+		if strings.TrimSpace(line) == "" {
+			p.blockRows[row] = true
+			continue
+		}
+		lineIndent := len(line) - len(trimmed)
+		if indent < 0 {
+			indent = lineIndent
+		}
+		if lineIndent < indent {
+			return
+		}
+
+		p.blockRows[row] = true
+		start := p.lines[row] + indent
+		end := p.lineEnd(row)
+		p.regions = append(p.regions, configRegion{start: start, end: end, value: p.src[start:end]})
+	}
+}
+
+// comments - комментарии вне блоков: '#' в начале строки или после пробела, вне кавычек
+func (p *yamlParser) comments() {
+	for row := range p.lines {
+		if p.blockRows[row] {
+			continue
+		}
+		start, end := p.lines[row], p.lineEnd(row)
+		if pos := commentStart(p.src[start:end]); pos >= 0 {
+			p.regions = append(p.regions, configRegion{start: start + pos + 1, end: end, value: p.src[start+pos+1 : end]})
+		}
+	}
+}
+
+func (p *yamlParser) lineEnd(row int) int {
+	end := len(p.src)
+	if row+1 < len(p.lines) {
+		end = p.lines[row+1] - 1
+	} else if end > p.lines[row] && p.src[end-1] == '\n' {
+		end--
+	}
+	if end > p.lines[row] && p.src[end-1] == '\r' {
+		end--
+	}
+	return end
+}
+
+// commentStart возвращает позицию '#', начинающего комментарий, или -1
+func commentStart(line string) int {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			if i == 0 || strings.ContainsRune(" \t:[{,-=", rune(line[i-1])) {
+				quote = c
+			}
+		case c == '#':
+			if i == 0 || line[i-1] == ' ' || line[i-1] == '\t' {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func closingYAMLQuote(s string, start int) int {
+	quote := s[start]
+	for i := start + 1; i < len(s); i++ {
+		switch {
+		case quote == '"' && s[i] == '\\':
+			i++
+		case s[i] == quote && quote == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			i++
+		case s[i] == quote:
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+// yamlPlainSafe - можно ли записать значение простым скаляром без кавычек
+func yamlPlainSafe(value string) bool {
+	if value == "" || strings.TrimSpace(value) != value {
+		return false
+	}
+	if strings.ContainsRune("[]{}&*!|>'\"%@`#,?:-", rune(value[0])) {
+		return false
+	}
+	return !strings.Contains(value, ": ") && !strings.Contains(value, " #") &&
+		!strings.HasSuffix(value, ":") && !strings.ContainsAny(value, "\n\r\t")
+}
+
+func selectedPath(selectors []jsonSelector, path []jsonPathElem) bool {
+	if len(selectors) == 0 {
+		return true
+	}
+	for _, sel := range selectors {
+		if sel.match(path) {
+			return true
+		}
+	}
+	return false
+}
+
+func lineOffsets(s string) []int {
+	offsets := []int{0}
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\n' && i+1 < len(s) {
+			offsets = append(offsets, i+1)
+		}
+	}
+	return offsets
+}
+
+// runeOffset переводит колонку в символах в байтовое смещение внутри строки
+func runeOffset(s string, lineStart, lineEnd, column int) int {
+	pos := lineStart
+	for ; column > 0 && pos < lineEnd; column-- {
+		_, size := utf8.DecodeRuneInString(s[pos:])
+		pos += size
+	}
+	return pos
+}
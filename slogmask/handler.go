@@ -0,0 +1,118 @@
+// Package slogmask - обертка над slog.Handler, которая маскирует сообщение
+// и строковые атрибуты записи до того, как она попадет во внутренний обработчик:
+//
+//	masker, _ := masking.ParseRules("link,phone", "stars", nil)
+//	logger := slog.New(slogmask.NewHandler(slog.NewJSONHandler(os.Stdout, nil), masker))
+package slogmask
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"LinkMaskirator/masking"
+)
+
+// Handler маскирует сообщение, строковые атрибуты (в том числе во вложенных
+// группах), ошибки и значения с методом String. Остальные атрибуты
+// передаются без изменений.
+type Handler struct {
+	inner  slog.Handler
+	masker *masking.Masker
+}
+
+// NewHandler оборачивает inner. masker == nil - маскируются только ссылки.
+func NewHandler(inner slog.Handler, masker *masking.Masker) *Handler {
+	if masker == nil {
+		masker = masking.NewLinkMasker()
+	}
+	return &Handler{inner: inner, masker: masker}
+}
+
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level)
+}
+
+func (h *Handler) Handle(ctx context.Context, record slog.Record) error {
+	message := h.masker.Mask(record.Message)
+	changed := message != record.Message
+
+	// Атрибуты собираются в буфер на стеке; если ничего не изменилось,
+	// во внутренний обработчик уходит исходная запись без копирования
+	var buf [8]slog.Attr
+	attrs := buf[:0]
+	record.Attrs(func(attr slog.Attr) bool {
+		masked, ok := h.maskAttr(attr)
+		changed = changed || ok
+		attrs = append(attrs, masked)
+		return true
+	})
+	if !changed {
+		return h.inner.Handle(ctx, record)
+	}
+
+	masked := slog.NewRecord(record.Time, record.Level, message, record.PC)
+	masked.AddAttrs(attrs...)
+	return h.inner.Handle(ctx, masked)
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	masked := make([]slog.Attr, len(attrs))
+	for i, attr := range attrs {
+		masked[i], _ = h.maskAttr(attr)
+	}
+	return &Handler{inner: h.inner.WithAttrs(masked), masker: h.masker}
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{inner: h.inner.WithGroup(name), masker: h.masker}
+}
+
+// maskAttr возвращает замаскированный атрибут и признак того, что он изменился
+func (h *Handler) maskAttr(attr slog.Attr) (slog.Attr, bool) {
+	value := attr.Value.Resolve()
+
+	switch value.Kind() {
+	case slog.KindString:
+		masked := h.masker.Mask(value.String())
+		if masked == value.String() {
+			return attr, false
+		}
+		return slog.String(attr.Key, masked), true
+
+	case slog.KindGroup:
+		group := value.Group()
+		var masked []slog.Attr
+		for i, item := range group {
+			maskedItem, ok := h.maskAttr(item)
+			if !ok {
+				continue
+			}
+			if masked == nil {
+				masked = append(make([]slog.Attr, 0, len(group)), group...)
+			}
+			masked[i] = maskedItem
+		}
+		if masked == nil {
+			return attr, false
+		}
+		return slog.Attr{Key: attr.Key, Value: slog.GroupValue(masked...)}, true
+
+	case slog.KindAny:
+		var text string
+		switch v := value.Any().(type) {
+		case error:
+			text = v.Error()
+		case fmt.Stringer:
+			text = v.String()
+		default:
+			return attr, false
+		}
+		masked := h.masker.Mask(text)
+		if masked == text {
+			return attr, false
+		}
+		return slog.String(attr.Key, masked), true
+	}
+	return attr, false
+}
@@ -0,0 +1,97 @@
+package slogmask
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"LinkMaskirator/masking"
+)
+
+func newTestLogger(t *testing.T, masker *masking.Masker) (*slog.Logger, *bytes.Buffer) {
+	t.Helper()
+	var buf bytes.Buffer
+	inner := slog.NewJSONHandler(&buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey && len(groups) == 0 {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	return slog.New(NewHandler(inner, masker)), &buf
+}
+
+func decodeRecord(t *testing.T, buf *bytes.Buffer) map[string]any {
+	t.Helper()
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	buf.Reset()
+	return record
+}
+
+type endpoint struct{ url string }
+
+func (e endpoint) String() string { return "endpoint " + e.url }
+
+func TestHandler(t *testing.T) {
+	logger, buf := newTestLogger(t, nil)
+
+	logger.Info("запрос к http://a.io/x",
+		"url", "https://b.io",
+		"status", 200,
+		slog.Group("upstream", "host", "http://c.io", slog.Group("retry", "to", "http://d.io")),
+		"error", errors.New("dial http://e.io: timeout"),
+		"target", endpoint{url: "http://f.io"},
+	)
+
+	record := decodeRecord(t, buf)
+	assert.Equal(t, "запрос к http://******", record["msg"])
+	assert.Equal(t, "https://****", record["url"])
+	assert.Equal(t, float64(200), record["status"])
+	assert.Equal(t, map[string]any{
+		"host":  "http://****",
+		"retry": map[string]any{"to": "http://****"},
+	}, record["upstream"])
+	assert.Equal(t, "dial http://***** timeout", record["error"])
+	assert.Equal(t, "endpoint http://****", record["target"])
+}
+
+func TestHandler_WithAttrsAndGroup(t *testing.T) {
+	masker, err := masking.ParseRules("link,phone:last4", "stars", []string{"ru"})
+	require.NoError(t, err)
+	logger, buf := newTestLogger(t, masker)
+
+	logger.With("base", "http://a.io").WithGroup("req").Info("звонок", "phone", "+7 912 345-67-89")
+
+	record := decodeRecord(t, buf)
+	assert.Equal(t, "http://****", record["base"])
+	assert.Equal(t, map[string]any{"phone": "+* *** ***-67-89"}, record["req"])
+}
+
+type countingHandler struct {
+	slog.Handler
+	records []slog.Record
+}
+
+func (h *countingHandler) Handle(_ context.Context, r slog.Record) error {
+	h.records = append(h.records, r)
+	return nil
+}
+
+func TestHandler_Unchanged(t *testing.T) {
+	inner := &countingHandler{Handler: slog.NewTextHandler(&bytes.Buffer{}, nil)}
+	logger := slog.New(NewHandler(inner, nil))
+
+	logger.Info("без ссылок", "n", 1, "s", "text")
+
+	require.Len(t, inner.records, 1)
+	assert.Equal(t, "без ссылок", inner.records[0].Message)
+	assert.Equal(t, 2, inner.records[0].NumAttrs())
+}